@@ -1,20 +1,57 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/app"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/cli"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/config"
 	"github.com/joho/godotenv"
+	"io/fs"
+	"os"
 )
 
-func init() {
-	if err := godotenv.Load(); err != nil {
-		panic("No .env file found")
+func main() {
+	// .env is optional, in containers the variables are usually set by the orchestrator
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		exit("failed to load .env file", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		exit("failed to load config", err)
+	}
+
+	// Any positional arguments after the global flags select an operational subcommand
+	if flag.NArg() > 0 {
+		if err = runCommand(cfg, flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	server, err := app.New(cfg)
+	if err != nil {
+		exit("failed to start app", err)
+	}
+	if err = server.Run(); err != nil {
+		exit("app stopped with error", err)
 	}
 }
 
-func main() {
-	cfg := config.MustLoad()
+func runCommand(cfg *config.Config, args []string) error {
+	c, err := cli.New(cfg, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.Run(context.Background(), args)
+}
 
-	server := app.New(cfg)
-	server.MustRun()
+func exit(msg string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", msg, err)
+	os.Exit(1)
 }
@@ -2,14 +2,23 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/config"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/handlers"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/logger"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/metrics"
 	mwr "github.com/ArtemSarafannikov/AvitoTestTask/internal/middleware"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/repository"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/service"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
-	"github.com/labstack/gommon/log"
+	"github.com/labstack/gommon/bytes"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,30 +27,99 @@ import (
 )
 
 type App struct {
-	config  *config.Config
-	server  *echo.Echo
-	handler *handlers.Handler
+	config          *config.Config
+	server          *echo.Echo
+	handler         *handlers.Handler
+	userService     *service.UserService
+	coinService     *service.CoinService
+	healthService   *service.HealthService
+	logger          *slog.Logger
+	jwtSecret       string
+	closeDB         func()
+	shutdownTracing func(context.Context) error
 }
 
-func New(config *config.Config) *App {
-	s := echo.New()
+func New(config *config.Config) (*App, error) {
+	const op = "App.New"
+
+	if err := validateConfig(config); err != nil {
+		return nil, fmt.Errorf("%s: invalid config: %w", op, err)
+	}
+	jwtSecret, exist := os.LookupEnv("JWT_SECRET")
+	if !exist || jwtSecret == "" {
+		return nil, fmt.Errorf("%s: JWT_SECRET environment variable is not set", op)
+	}
+
+	log, err := logger.New(config.Log)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	slog.SetDefault(log)
+	shutdownTracing, err := tracing.Setup(context.Background(), config.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to set up tracing: %w", op, err)
+	}
 	repo, err := repository.NewPostgresRepository(config.Storage)
 	if err != nil {
-		panic(err)
+		_ = shutdownTracing(context.Background())
+		return nil, fmt.Errorf("%s: failed to connect to database: %w", op, err)
+	}
+	metrics.RegisterDBStats(repo.Stats)
+
+	s := echo.New()
+	s.HideBanner = true
+	s.HidePort = true
+	for _, srv := range []*http.Server{s.Server, s.TLSServer} {
+		srv.ReadTimeout = config.Server.ReadTimeout
+		srv.WriteTimeout = config.Server.WriteTimeout
+		srv.IdleTimeout = config.Server.IdleTimeout
 	}
+
 	userService := service.NewUserService(repo)
 	transactionService := service.NewTransactionService(repo)
+	merchService := service.NewMerchService(repo)
+	cartService := service.NewCartService(repo)
+	wishlistService := service.NewWishlistService(repo)
+	coinService := service.NewCoinService(repo, service.AllowancePolicy{
+		Amount: config.Allowance.Amount,
+		Period: config.Allowance.Period,
+		TTL:    config.Allowance.TTL,
+	})
+	leaderboardService := service.NewLeaderboardService(repo, config.Leaderboard.CacheTTL)
+	healthService := service.NewHealthService(repo)
 	return &App{
-		config:  config,
-		server:  s,
-		handler: handlers.NewHandler(s.Logger, userService, transactionService),
-	}
+		config: config,
+		server: s,
+		handler: handlers.NewHandler(userService, transactionService, merchService, cartService,
+			wishlistService, coinService, leaderboardService, healthService),
+		userService:     userService,
+		coinService:     coinService,
+		healthService:   healthService,
+		logger:          log,
+		jwtSecret:       jwtSecret,
+		closeDB:         repo.Close,
+		shutdownTracing: shutdownTracing,
+	}, nil
 }
 
-func (a *App) MustRun() {
-	if err := a.Run(); err != nil {
-		panic(err)
+// validateConfig checks settings that cleanenv can't, before any connection is opened
+func validateConfig(config *config.Config) error {
+	if config.Port <= 0 || config.Port > 65535 {
+		return fmt.Errorf("port %d is out of range", config.Port)
+	}
+	if config.Server.ShutdownDrainDelay < 0 {
+		return fmt.Errorf("server shutdown drain delay %s is negative", config.Server.ShutdownDrainDelay)
 	}
+	if _, err := bytes.Parse(config.Server.BodyLimit); err != nil {
+		return fmt.Errorf("server body limit %q: %w", config.Server.BodyLimit, err)
+	}
+	if config.Server.TLSEnabled() && (config.Server.TLSCertFile == "" || config.Server.TLSKeyFile == "") {
+		return errors.New("both tls_cert_file and tls_key_file must be set to enable TLS")
+	}
+	if config.Allowance.Amount > 0 && !model.IsValidAllowancePeriod(config.Allowance.Period) {
+		return fmt.Errorf("unknown allowance period %q", config.Allowance.Period)
+	}
+	return nil
 }
 
 func (a *App) Run() error {
@@ -52,44 +130,123 @@ func (a *App) Run() error {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	schedulerDone := make(chan struct{})
 	go func() {
-		if err := a.server.Start(":8080"); err != nil && err != http.ErrServerClosed {
-			a.server.Logger.Fatalf("%s: %w", op, err)
-			return
+		defer close(schedulerDone)
+		a.runScheduler(schedulerCtx, a.config.Allowance.CheckInterval)
+	}()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		address := a.config.Address()
+		a.logger.Info("server started", slog.String("op", op), slog.String("address", address),
+			slog.Bool("tls", a.config.Server.TLSEnabled()))
+		var err error
+		if a.config.Server.TLSEnabled() {
+			err = a.server.StartTLS(address, a.config.Server.TLSCertFile, a.config.Server.TLSKeyFile)
+		} else {
+			err = a.server.Start(address)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serverErr <- err
 		}
 	}()
 
-	<-quit
-	a.server.Logger.Infof("%s: %s", op, "Shutting down server...")
+	var runErr error
+	select {
+	case <-quit:
+		a.logger.Info("shutting down server", slog.String("op", op))
+	case err := <-serverErr:
+		runErr = fmt.Errorf("%s: server failed: %w", op, err)
+		a.logger.Error("server failed, shutting down", slog.String("op", op), slog.Any("error", err))
+	}
+	a.healthService.SetShuttingDown()
+	stopScheduler()
+	if runErr == nil {
+		// Requests keep being served until load balancers see the failing readiness probe
+		time.Sleep(a.config.Server.ShutdownDrainDelay)
+	}
+	<-schedulerDone
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), a.config.Server.ShutdownTimeout)
 	defer shutdownCancel()
 
 	if err := a.server.Shutdown(shutdownCtx); err != nil {
-		a.server.Logger.Fatalf("%s: %w", op, err)
-		return err
+		a.logger.Error("server shutdown failed", slog.String("op", op), slog.Any("error", err))
+		runErr = errors.Join(runErr, fmt.Errorf("%s: server shutdown: %w", op, err))
+	}
+	a.closeDB()
+	if err := a.shutdownTracing(shutdownCtx); err != nil {
+		a.logger.Error("tracing shutdown failed", slog.String("op", op), slog.Any("error", err))
 	}
 
-	a.server.Logger.Infof("%s: %s", op, "graceful shutdown complete")
+	if runErr != nil {
+		return runErr
+	}
+	a.logger.Info("graceful shutdown complete", slog.String("op", op))
 	return nil
 }
 
 func (a *App) SetupHandlers() {
-	a.server.Use(middleware.Logger())
+	a.server.Use(otelecho.Middleware(a.config.Tracing.ServiceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		switch c.Path() {
+		case "/metrics", "/healthz", "/readyz":
+			return true
+		}
+		return false
+	})))
+	a.server.Use(mwr.RequestIdMiddleware(a.logger))
+	a.server.Use(mwr.RequestLoggerMiddleware)
+	a.server.Use(mwr.MetricsMiddleware)
 	a.server.Use(middleware.Recover())
-	a.server.Logger.SetLevel(log.INFO)
+	a.server.Use(middleware.BodyLimit(a.config.Server.BodyLimit))
+	a.server.HTTPErrorHandler = a.handler.HTTPErrorHandler
+
+	a.server.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+	a.server.GET("/healthz", a.handler.Healthz)
+	a.server.GET("/readyz", a.handler.Readyz)
 
 	a.server.POST("/api/auth", a.handler.AuthHandler)
 
 	withAuthGroup := a.server.Group("/api")
 
-	JWTSecret, exist := os.LookupEnv("JWT_SECRET")
-	if !exist {
-		a.server.Logger.Fatal("JWT_SECRET environment variable not set")
-	}
-	withAuthGroup.Use(mwr.JWTMiddleware(JWTSecret))
+	withAuthGroup.Use(mwr.JWTMiddleware(a.jwtSecret))
 	withAuthGroup.Use(mwr.AuthMiddleware)
 	withAuthGroup.GET("/info", a.handler.GetInfo)
 	withAuthGroup.POST("/sendCoin", a.handler.SendCoin)
+	withAuthGroup.POST("/sendCoin/bulk", a.handler.BulkSendCoin)
 	withAuthGroup.GET("/buy/:item", a.handler.BuyItem)
+	withAuthGroup.GET("/merch", a.handler.GetCatalog)
+	withAuthGroup.GET("/leaderboard", a.handler.GetLeaderboard)
+	withAuthGroup.GET("/purchases", a.handler.GetPurchases)
+	withAuthGroup.POST("/purchases/:id/transfer", a.handler.TransferItem)
+	withAuthGroup.GET("/cart", a.handler.GetCart)
+	withAuthGroup.POST("/cart", a.handler.AddToCart)
+	withAuthGroup.DELETE("/cart/:item", a.handler.RemoveFromCart)
+	withAuthGroup.POST("/cart/checkout", a.handler.Checkout)
+	withAuthGroup.GET("/wishlist", a.handler.GetWishlist)
+	withAuthGroup.POST("/wishlist", a.handler.AddToWishlist)
+	withAuthGroup.DELETE("/wishlist/:item", a.handler.RemoveFromWishlist)
+
+	adminGroup := withAuthGroup.Group("/admin")
+	adminGroup.Use(mwr.AdminMiddleware(a.userService.IsAdmin))
+	adminGroup.POST("/grant", a.handler.AdminGrantCoins)
+	adminGroup.POST("/deduct", a.handler.AdminDeductCoins)
+	adminGroup.POST("/grant/bulk", a.handler.AdminBulkGrantCoins)
+	adminGroup.POST("/transactions/:id/reverse", a.handler.AdminReverseTransaction)
+	adminGroup.POST("/purchases/:id/reverse", a.handler.AdminReversePurchase)
+	adminGroup.PUT("/purchases/:id/status", a.handler.AdminChangeOrderStatus)
+	adminGroup.GET("/merch/lowStock", a.handler.AdminLowStockReport)
+	adminGroup.PUT("/merch/:name/stock", a.handler.AdminSetMerchStock)
+	adminGroup.PUT("/merch/:name/limit", a.handler.AdminSetMerchLimit)
+	adminGroup.POST("/merch/:name/restock", a.handler.AdminRestockMerch)
+	adminGroup.PUT("/merch/:name/details", a.handler.AdminSetMerchDetails)
+	adminGroup.POST("/merch/:name/variants", a.handler.AdminAddMerchVariant)
+	adminGroup.PUT("/variants/:id/stock", a.handler.AdminSetVariantStock)
+	adminGroup.POST("/merch/:name/prices", a.handler.AdminCreatePriceSchedule)
+	adminGroup.GET("/merch/:name/prices", a.handler.AdminGetPriceSchedules)
+	adminGroup.DELETE("/prices/:id", a.handler.AdminDeletePriceSchedule)
+	adminGroup.POST("/promo", a.handler.AdminCreatePromoCode)
+	adminGroup.GET("/promo", a.handler.AdminGetPromoCodes)
 }
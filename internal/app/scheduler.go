@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const defaultSchedulerInterval = time.Hour
+
+// runScheduler expires coin lots and issues periodic allowances at startup and then
+// every interval until ctx is cancelled
+func (a *App) runScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSchedulerInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		a.runCoinJobs(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runCoinJobs expires stale coins before issuing the allowance, so coins of the previous period
+// never pile up with the new ones. Failed jobs are retried on the next tick.
+func (a *App) runCoinJobs(ctx context.Context, now time.Time) {
+	const op = "App.runCoinJobs"
+
+	log := a.logger.With(slog.String("op", op))
+
+	expired, err := a.coinService.ExpireCoins(ctx, now)
+	if err != nil {
+		log.Error("coins expiration failed", slog.Any("error", err))
+	} else if expired > 0 {
+		log.Info("coins expired", slog.Int("coins", expired))
+	}
+
+	issued, err := a.coinService.IssueAllowance(ctx, now)
+	if err != nil {
+		log.Error("allowance failed", slog.Any("error", err))
+	} else if issued > 0 {
+		log.Info("allowance issued", slog.Int("users", issued))
+	}
+}
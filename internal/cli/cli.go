@@ -0,0 +1,349 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/config"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/repository"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/service"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+type CLI struct {
+	out                io.Writer
+	userService        *service.UserService
+	transactionService *service.TransactionService
+	merchService       *service.MerchService
+	commands           map[string]command
+	closeDB            func()
+}
+
+func New(config *config.Config, out io.Writer) (*CLI, error) {
+	repo, err := repository.NewPostgresRepository(config.Storage)
+	if err != nil {
+		return nil, err
+	}
+	c := &CLI{
+		out:                out,
+		userService:        service.NewUserService(repo),
+		transactionService: service.NewTransactionService(repo),
+		merchService:       service.NewMerchService(repo),
+		closeDB:            repo.Close,
+	}
+	c.registerCommands()
+	return c, nil
+}
+
+// Close releases the database connections
+func (c *CLI) Close() {
+	if c.closeDB != nil {
+		c.closeDB()
+	}
+}
+
+func (c *CLI) registerCommands() {
+	c.commands = map[string]command{
+		"create-user":       {"--username NAME --password PASS", c.createUser},
+		"grant-coins":       {"--user NAME --amount N --reason TEXT", c.grantCoins},
+		"deduct-coins":      {"--user NAME --amount N --reason TEXT", c.deductCoins},
+		"set-admin":         {"--user NAME [--admin=false]", c.setAdmin},
+		"create-merch":      {"--name NAME --price N [--stock N] [--selling=false]", c.createMerch},
+		"restock-merch":     {"--name NAME --quantity N", c.restockMerch},
+		"set-merch-limit":   {"--name NAME --max N (0 removes the limit)", c.setMerchLimit},
+		"set-merch-selling": {"--name NAME --selling=true|false", c.setMerchSelling},
+		"show-balance":      {"--user NAME", c.showBalance},
+		"export-history":    {"--user NAME [--category C] [--format json|csv] [--output FILE]", c.exportHistory},
+	}
+}
+
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		c.printUsage()
+		return errors.New("command is not specified")
+	}
+	cmd, ok := c.commands[args[0]]
+	if !ok {
+		c.printUsage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	if err := cmd.run(ctx, args[1:]); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
+
+func (c *CLI) printUsage() {
+	names := make([]string, 0, len(c.commands))
+	for name := range c.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(c.out, "Usage: main [--config=PATH] <command> [flags]")
+	fmt.Fprintln(c.out, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(c.out, "  %-18s %s\n", name, c.commands[name].usage)
+	}
+}
+
+func (c *CLI) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.out)
+	return fs
+}
+
+func (c *CLI) lookupUser(ctx context.Context, username string) (*model.User, error) {
+	user, err := c.userService.GetUserByUsername(ctx, username)
+	if errors.Is(err, cstErrors.NotFoundError) {
+		return nil, fmt.Errorf("user %q not found", username)
+	}
+	return user, err
+}
+
+func (c *CLI) createUser(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("create-user")
+	username := fs.String("username", "", "login of the new user")
+	password := fs.String("password", "", "password of the new user")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" || *password == "" {
+		return cstErrors.BadRequestDataError
+	}
+
+	user, err := c.userService.Register(ctx, &model.User{Username: *username, Password: *password})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "created user %s (id %s, balance %d)\n", user.Username, user.Id, user.Balance)
+	return nil
+}
+
+func (c *CLI) grantCoins(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("grant-coins")
+	username := fs.String("user", "", "login of the recipient")
+	amount := fs.Int("amount", 0, "amount of coins to grant")
+	reason := fs.String("reason", "", "reason recorded in the transactions log")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := c.lookupUser(ctx, *username)
+	if err != nil {
+		return err
+	}
+	if err = c.transactionService.GrantCoins(ctx, user.Id, *amount, *reason); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "granted %d coins to %s\n", *amount, user.Username)
+	return nil
+}
+
+func (c *CLI) deductCoins(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("deduct-coins")
+	username := fs.String("user", "", "login of the user")
+	amount := fs.Int("amount", 0, "amount of coins to deduct")
+	reason := fs.String("reason", "", "reason recorded in the transactions log")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := c.lookupUser(ctx, *username)
+	if err != nil {
+		return err
+	}
+	if err = c.transactionService.DeductCoins(ctx, user.Id, *amount, *reason); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "deducted %d coins from %s\n", *amount, user.Username)
+	return nil
+}
+
+func (c *CLI) setAdmin(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("set-admin")
+	username := fs.String("user", "", "login of the user")
+	admin := fs.Bool("admin", true, "whether the user may call admin endpoints")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := c.lookupUser(ctx, *username)
+	if err != nil {
+		return err
+	}
+	if err = c.userService.SetAdmin(ctx, user.Id, *admin); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "user %s admin: %t\n", user.Username, *admin)
+	return nil
+}
+
+func (c *CLI) createMerch(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("create-merch")
+	name := fs.String("name", "", "unique name of the item")
+	price := fs.Int("price", 0, "price in coins")
+	selling := fs.Bool("selling", true, "whether the item is available for purchase")
+	stock := fs.Int("stock", -1, "items in stock, negative for unlimited")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var stockPtr *int
+	if *stock >= 0 {
+		stockPtr = stock
+	}
+	merch, err := c.merchService.CreateMerch(ctx, *name, *price, *selling, stockPtr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "created merch %s (id %s, price %d, selling %t)\n", merch.Name, merch.Id, merch.Price, merch.IsSelling)
+	return nil
+}
+
+func (c *CLI) restockMerch(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("restock-merch")
+	name := fs.String("name", "", "name of the item")
+	quantity := fs.Int("quantity", 0, "number of items to add to the stock")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	stock, err := c.merchService.Restock(ctx, *name, *quantity)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "merch %s stock: %d\n", *name, stock)
+	return nil
+}
+
+func (c *CLI) setMerchSelling(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("set-merch-selling")
+	name := fs.String("name", "", "name of the item")
+	selling := fs.Bool("selling", true, "whether the item is available for purchase")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := c.merchService.SetMerchSelling(ctx, *name, *selling); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "merch %s selling: %t\n", *name, *selling)
+	return nil
+}
+
+func (c *CLI) setMerchLimit(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("set-merch-limit")
+	name := fs.String("name", "", "name of the item")
+	maxPerUser := fs.Int("max", 0, "max items per user, 0 removes the limit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var limit *int
+	if *maxPerUser != 0 {
+		limit = maxPerUser
+	}
+	if err := c.merchService.SetMaxPerUser(ctx, *name, limit); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "merch %s max per user: %d\n", *name, *maxPerUser)
+	return nil
+}
+
+func (c *CLI) showBalance(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("show-balance")
+	username := fs.String("user", "", "login of the user")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := c.lookupUser(ctx, *username)
+	if err != nil {
+		return err
+	}
+	balance, err := c.userService.GetUserBalance(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "%s: %d\n", user.Username, balance)
+	return nil
+}
+
+func (c *CLI) exportHistory(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("export-history")
+	username := fs.String("user", "", "login of the user")
+	category := fs.String("category", "", "export only transfers of this category")
+	format := fs.String("format", "json", "output format: json or csv")
+	output := fs.String("output", "", "output file (stdout if empty)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+
+	user, err := c.lookupUser(ctx, *username)
+	if err != nil {
+		return err
+	}
+	history, err := c.transactionService.GetTransactionsHistory(ctx, user.Id, *category)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return writeHistory(c.out, *format, history)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err = writeHistory(f, *format, history); err != nil {
+		_ = f.Close()
+		return err
+	}
+	// Data is flushed to disk on close, a failed close means the export is incomplete
+	return f.Close()
+}
+
+func writeHistory(out io.Writer, format string, history *model.CoinHistory) error {
+	if format == "csv" {
+		return writeHistoryCSV(out, history)
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(history)
+}
+
+func writeHistoryCSV(out io.Writer, history *model.CoinHistory) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"direction", "user", "amount", "reason", "category", "message"}); err != nil {
+		return err
+	}
+	for _, r := range history.Received {
+		if err := w.Write([]string{"received", r.FromUser, strconv.Itoa(r.Amount), r.Reason, r.Category, r.Message}); err != nil {
+			return err
+		}
+	}
+	for _, s := range history.Sent {
+		if err := w.Write([]string{"sent", s.ToUser, strconv.Itoa(s.Amount), s.Reason, s.Category, s.Message}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
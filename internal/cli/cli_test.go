@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// newTestCLI returns a CLI without services, commands that fail before reaching them can be run
+func newTestCLI(out *bytes.Buffer) *CLI {
+	c := &CLI{out: out}
+	c.registerCommands()
+	return c
+}
+
+// --- Tests for CLI.Run ---
+
+func TestCLI_Run_Usage(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "no command", args: nil, wantErr: "command is not specified"},
+		{name: "unknown command", args: []string{"drop-db"}, wantErr: `unknown command "drop-db"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c := newTestCLI(&out)
+
+			err := c.Run(context.Background(), tt.args)
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Contains(t, out.String(), "Usage: main [--config=PATH] <command> [flags]")
+			for name, cmd := range c.commands {
+				assert.Contains(t, out.String(), name)
+				assert.Contains(t, out.String(), cmd.usage)
+			}
+		})
+	}
+}
+
+func TestCLI_Run_BadArguments(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		target  error
+		wantErr string
+	}{
+		{
+			name:   "missing password",
+			args:   []string{"create-user", "--username", "alice"},
+			target: cstErrors.BadRequestDataError,
+		},
+		{
+			name:   "missing username",
+			args:   []string{"create-user", "--password", "secret"},
+			target: cstErrors.BadRequestDataError,
+		},
+		{
+			name:    "amount is not a number",
+			args:    []string{"grant-coins", "--user", "alice", "--amount", "ten"},
+			wantErr: `grant-coins: invalid value "ten" for flag -amount: parse error`,
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"deduct-coins", "--users", "alice"},
+			wantErr: "deduct-coins: flag provided but not defined: -users",
+		},
+		{
+			name:    "bad boolean",
+			args:    []string{"set-merch-selling", "--name", "cup", "--selling=maybe"},
+			wantErr: `set-merch-selling: invalid boolean value "maybe" for -selling: parse error`,
+		},
+		{
+			name:    "unsupported export format",
+			args:    []string{"export-history", "--user", "alice", "--format", "xml"},
+			wantErr: `export-history: unsupported format "xml"`,
+		},
+		{
+			name:   "help",
+			args:   []string{"restock-merch", "-h"},
+			target: flag.ErrHelp,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c := newTestCLI(&out)
+
+			err := c.Run(context.Background(), tt.args)
+			if tt.target != nil {
+				assert.True(t, errors.Is(err, tt.target), "got %v", err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCLI_Run_FlagHelpPrinted(t *testing.T) {
+	var out bytes.Buffer
+	c := newTestCLI(&out)
+
+	err := c.Run(context.Background(), []string{"restock-merch", "-h"})
+	assert.Error(t, err)
+	assert.Contains(t, out.String(), "-quantity")
+	assert.Contains(t, out.String(), "number of items to add to the stock")
+}
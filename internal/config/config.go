@@ -2,41 +2,123 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
+	"net"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 )
 
 var once sync.Once
 
 type Config struct {
-	Port    int            `json:"port" env-required:"true"`
-	Storage DatabaseConfig `json:"storage" env-required:"true"`
+	Port        int               `yaml:"port" env:"PORT" env-required:"true"`
+	Storage     DatabaseConfig    `yaml:"storage"`
+	Server      ServerConfig      `yaml:"server" env-prefix:"SERVER_"`
+	Allowance   AllowanceConfig   `yaml:"allowance" env-prefix:"ALLOWANCE_"`
+	Leaderboard LeaderboardConfig `yaml:"leaderboard" env-prefix:"LEADERBOARD_"`
+	Tracing     TracingConfig     `yaml:"tracing" env-prefix:"TRACING_"`
+	Log         LogConfig         `yaml:"log" env-prefix:"LOG_"`
+}
+
+type ServerConfig struct {
+	Host               string        `yaml:"host" env:"HOST"` // empty means all interfaces
+	ReadTimeout        time.Duration `yaml:"read_timeout" env:"READ_TIMEOUT" env-default:"10s"`
+	WriteTimeout       time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT" env-default:"10s"`
+	IdleTimeout        time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT" env-default:"60s"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"10s"`
+	ShutdownDrainDelay time.Duration `yaml:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY" env-default:"5s"` // serving after /readyz reports shutdown
+	BodyLimit          string        `yaml:"body_limit" env:"BODY_LIMIT" env-default:"1M"`                     // e.g. 512K, 2M
+	TLSCertFile        string        `yaml:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile         string        `yaml:"tls_key_file" env:"TLS_KEY_FILE"`
 }
 
 type DatabaseConfig struct {
-	Address  string `yaml:"db_address" env-required:"true"`
-	Name     string `yaml:"db_name" env-required:"true"`
-	User     string `yaml:"db_user" env-required:"true"`
-	Password string `yaml:"db_password" env-required:"true"`
-	SSLMode  string `yaml:"db_sslmode" env-required:"true"`
+	Address  string `yaml:"db_address" env:"DB_ADDRESS" env-required:"true"`
+	Name     string `yaml:"db_name" env:"DB_NAME" env-required:"true"`
+	User     string `yaml:"db_user" env:"DB_USER" env-required:"true"`
+	Password string `yaml:"db_password" env:"DB_PASSWORD" env-required:"true"`
+	SSLMode  string `yaml:"db_sslmode" env:"DB_SSLMODE" env-required:"true"`
+
+	// DB_MAX_OPEN_CONNS is the name used before the switch to pgxpool, it is still read as a fallback
+	MaxConns        int           `yaml:"db_max_conns" env:"DB_MAX_CONNS,DB_MAX_OPEN_CONNS" env-default:"25"`
+	MinConns        int           `yaml:"db_min_conns" env:"DB_MIN_CONNS"` // connections kept open when idle
+	ConnMaxLifetime time.Duration `yaml:"db_conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"db_conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" env-default:"5m"`
+
+	// Prepared statements cached per connection, 0 disables caching
+	StatementCacheCapacity int `yaml:"db_statement_cache_capacity" env:"DB_STATEMENT_CACHE_CAPACITY" env-default:"512"`
+
+	// Startup waits for the database: up to ConnectAttempts pings,
+	// the delay starts at ConnectBackoff and doubles up to ConnectMaxBackoff
+	ConnectAttempts   int           `yaml:"db_connect_attempts" env:"DB_CONNECT_ATTEMPTS" env-default:"10"`
+	ConnectBackoff    time.Duration `yaml:"db_connect_backoff" env:"DB_CONNECT_BACKOFF" env-default:"500ms"`
+	ConnectMaxBackoff time.Duration `yaml:"db_connect_max_backoff" env:"DB_CONNECT_MAX_BACKOFF" env-default:"10s"`
 }
 
-func MustLoad() *Config {
+type AllowanceConfig struct {
+	Amount        int           `yaml:"amount" env:"AMOUNT"` // 0 disables periodic allowances
+	Period        string        `yaml:"period" env:"PERIOD" env-default:"month"`
+	TTL           time.Duration `yaml:"ttl" env:"TTL"` // 0 means coins expire when the period ends
+	CheckInterval time.Duration `yaml:"check_interval" env:"CHECK_INTERVAL" env-default:"1h"`
+}
+
+type LeaderboardConfig struct {
+	CacheTTL time.Duration `yaml:"cache_ttl" env:"CACHE_TTL"` // 0 disables caching
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"EXPORTER" env-default:"none"`           // none, stdout or otlp
+	Endpoint    string  `yaml:"endpoint" env:"ENDPOINT" env-default:"localhost:4318"` // OTLP/HTTP collector address
+	Insecure    bool    `yaml:"insecure" env:"INSECURE"`
+	ServiceName string  `yaml:"service_name" env:"SERVICE_NAME" env-default:"merch-store"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"SAMPLE_RATIO" env-default:"1"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" env:"LEVEL" env-default:"info"` // debug, info, warn or error
+}
+
+// Address returns the address the server listens on
+func (c *Config) Address() string {
+	return net.JoinHostPort(c.Server.Host, strconv.Itoa(c.Port))
+}
+
+// TLSEnabled reports whether the server is served over HTTPS
+func (c *ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSKeyFile != ""
+}
+
+// Load reads the config file passed by --config or CONFIG_PATH, environment variables override
+// its values. Without a config file the whole config is read from environment variables.
+func Load() (*Config, error) {
+	var cfg Config
+
 	path := fetchConfigPath()
 	if path == "" {
-		panic("config path is empty")
+		if err := cleanenv.ReadEnv(&cfg); err != nil {
+			return nil, fmt.Errorf("failed to read config from environment (no config file given): %w", err)
+		}
+		return &cfg, nil
 	}
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		panic("config path does not exist: " + path)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
 	}
-
-	var cfg Config
 	if err := cleanenv.ReadConfig(path, &cfg); err != nil {
-		panic("falied to read config: " + err.Error())
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func MustLoad() *Config {
+	cfg, err := Load()
+	if err != nil {
+		panic(err)
 	}
-	return &cfg
+	return cfg
 }
 
 func fetchConfigPath() string {
@@ -1,9 +1,12 @@
 package cstErrors
 
 import (
+	"context"
 	"errors"
-	"github.com/labstack/echo/v4"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/logger"
+	"log/slog"
 	"net/http"
+	"strings"
 )
 
 type KnownError interface {
@@ -35,9 +38,28 @@ var (
 	NotFoundError             = GenerateError(http.StatusNotFound, "Not found")
 	BadCredentialError        = GenerateError(http.StatusUnauthorized, "Bad credential")
 	UnauthorizedError         = GenerateError(http.StatusUnauthorized, "Authorize to this operation")
+	ForbiddenError            = GenerateError(http.StatusForbidden, "Not enough rights for this operation")
 	NoCoinError               = GenerateError(http.StatusBadRequest, "There are not enough coins in the balance for this operation")
 	NoSellingMerchError       = GenerateError(http.StatusBadRequest, "No selling merchant")
+	OutOfStockError           = GenerateError(http.StatusBadRequest, "Merch is out of stock")
+	StockNotTrackedError      = GenerateError(http.StatusBadRequest, "Stock of this merch is unlimited")
+	PurchaseLimitError        = GenerateError(http.StatusBadRequest, "Purchase limit for this merch is reached")
 	CantSendCoinYourselfError = GenerateError(http.StatusBadRequest, "Cant send coin to yourself")
+	UserAlreadyExistsError    = GenerateError(http.StatusConflict, "User already exists")
+	RecipientNotFoundError    = GenerateError(http.StatusBadRequest, "One or more recipients not found")
+	AlreadyReversedError      = GenerateError(http.StatusConflict, "Operation already reversed")
+	MerchAlreadyExistsError   = GenerateError(http.StatusConflict, "Merch already exists")
+	InvalidPromoCodeError     = GenerateError(http.StatusBadRequest, "Promo code is invalid or expired")
+	PromoNotApplicableError   = GenerateError(http.StatusBadRequest, "Promo code is not applicable to this merch")
+	PromoLimitError           = GenerateError(http.StatusBadRequest, "Promo code usage limit is reached")
+	PromoAlreadyExistsError   = GenerateError(http.StatusConflict, "Promo code already exists")
+	EmptyCartError            = GenerateError(http.StatusBadRequest, "Cart is empty")
+	OrderStatusError          = GenerateError(http.StatusConflict, "Order status cannot be changed this way")
+	VariantRequiredError      = GenerateError(http.StatusBadRequest, "Choose a variant of this merch")
+	VariantAlreadyExistsError = GenerateError(http.StatusConflict, "Variant already exists")
+	CantGiftYourselfError     = GenerateError(http.StatusBadRequest, "Cant gift merch to yourself")
+	CantTransferYourselfError = GenerateError(http.StatusBadRequest, "Cant transfer item to yourself")
+	ItemNotTransferableError  = GenerateError(http.StatusConflict, "Item of a cancelled purchase cannot be transferred")
 )
 
 func GenerateError(code int, err string) error {
@@ -52,14 +74,26 @@ func IsCustomError(err error) bool {
 	return errors.As(err, &knownError)
 }
 
-func GetAndLogCustomError(err error, logger echo.Logger) KnownError {
+// GetAndLogCustomError returns the error to show to the user. Unknown errors are logged with the logger
+// bound to ctx, so the log entry carries request_id and user_id.
+func GetAndLogCustomError(ctx context.Context, err error) KnownError {
 	if err == nil {
 		return nil
 	}
 	knErr, ok := err.(KnownError)
 	if !ok {
-		logger.Error(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "request failed", slog.String("op", errorOp(err)), slog.Any("error", err))
 		return InternalError.(KnownError)
 	}
 	return knErr
 }
+
+// errorOp returns the outermost op of the error. Errors are wrapped as "op: cause" on the way up
+// from the repository, so this is the service method that failed.
+func errorOp(err error) string {
+	op, _, found := strings.Cut(err.Error(), ": ")
+	if !found || strings.Contains(op, " ") {
+		return ""
+	}
+	return op
+}
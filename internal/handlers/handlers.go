@@ -1,44 +1,115 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/metrics"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/service"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/utils"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 	"net/http"
+	"strconv"
+)
+
+const (
+	defaultLowStockThreshold = 5
+	defaultLeaderboardLimit  = 10
 )
 
 type Handler struct {
-	logger             echo.Logger
 	userService        *service.UserService
 	transactionService *service.TransactionService
+	merchService       *service.MerchService
+	cartService        *service.CartService
+	wishlistService    *service.WishlistService
+	coinService        *service.CoinService
+	leaderboardService *service.LeaderboardService
+	healthService      *service.HealthService
 }
 
-func NewHandler(logger echo.Logger, userService *service.UserService, transactionService *service.TransactionService) *Handler {
+func NewHandler(userService *service.UserService, transactionService *service.TransactionService,
+	merchService *service.MerchService, cartService *service.CartService, wishlistService *service.WishlistService,
+	coinService *service.CoinService, leaderboardService *service.LeaderboardService, healthService *service.HealthService) *Handler {
 	return &Handler{
-		logger:             logger,
 		userService:        userService,
 		transactionService: transactionService,
+		merchService:       merchService,
+		cartService:        cartService,
+		wishlistService:    wishlistService,
+		coinService:        coinService,
+		leaderboardService: leaderboardService,
+		healthService:      healthService,
 	}
 }
 
 func (h *Handler) GetResponseError(c echo.Context, err error) error {
-	cstErr := cstErrors.GetAndLogCustomError(err, h.logger)
-	errResp := model.ErrorResponse{Errors: cstErr.Error()}
+	if errors.Is(err, cstErrors.NoCoinError) {
+		metrics.NoCoinErrors.Inc()
+	}
+	cstErr := cstErrors.GetAndLogCustomError(c.Request().Context(), err)
+	if !cstErrors.IsCustomError(err) {
+		span := trace.SpanFromContext(c.Request().Context())
+		span.RecordError(err)
+		span.SetStatus(codes.Error, cstErr.Error())
+	}
+	errResp := model.ErrorResponse{
+		Errors:    cstErr.Error(),
+		RequestId: c.Response().Header().Get(echo.HeaderXRequestID),
+	}
 	return c.JSON(cstErr.Code(), errResp)
 }
 
+// HTTPErrorHandler renders errors returned from handlers and middleware (unknown route, missing token)
+// in the same format as GetResponseError
+func (h *Handler) HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) {
+		_ = h.GetResponseError(c, err)
+		return
+	}
+	if c.Request().Method == http.MethodHead {
+		_ = c.NoContent(httpErr.Code)
+		return
+	}
+	_ = c.JSON(httpErr.Code, model.ErrorResponse{
+		Errors:    fmt.Sprint(httpErr.Message),
+		RequestId: c.Response().Header().Get(echo.HeaderXRequestID),
+	})
+}
+
+// Healthz is the liveness probe, it only reports that the process serves requests
+func (h *Handler) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": model.HealthStatusUp})
+}
+
+// Readyz is the readiness probe, it responds 503 while a dependency is down or the app is shutting down
+func (h *Handler) Readyz(c echo.Context) error {
+	resp := h.healthService.Readiness(c.Request().Context())
+	if !resp.Ready() {
+		return c.JSON(http.StatusServiceUnavailable, resp)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
 func (h *Handler) GetInfo(c echo.Context) error {
 	userId, ok := c.Get(utils.UserIdCtxKey).(string)
 	if !ok {
 		return h.GetResponseError(c, cstErrors.UnauthorizedError)
 	}
 	ctx := c.Request().Context()
+	category := c.QueryParam("category")
 
 	var (
 		coins     int
+		expiring  []*model.ExpiringCoins
 		inventory []*model.InfoInventory
 		history   *model.CoinHistory
 	)
@@ -51,6 +122,12 @@ func (h *Handler) GetInfo(c echo.Context) error {
 		return err
 	})
 
+	eg.Go(func() error {
+		var err error
+		expiring, err = h.coinService.GetExpiringCoins(ctx, userId)
+		return err
+	})
+
 	eg.Go(func() error {
 		var err error
 		inventory, err = h.transactionService.GetInventory(ctx, userId)
@@ -59,7 +136,7 @@ func (h *Handler) GetInfo(c echo.Context) error {
 
 	eg.Go(func() error {
 		var err error
-		history, err = h.transactionService.GetTransactionsHistory(ctx, userId)
+		history, err = h.transactionService.GetTransactionsHistory(ctx, userId, category)
 		return err
 	})
 
@@ -68,9 +145,10 @@ func (h *Handler) GetInfo(c echo.Context) error {
 	}
 
 	resp := model.InfoResponse{
-		Balance:     coins,
-		Inventory:   inventory,
-		CoinHistory: history,
+		Balance:       coins,
+		ExpiringCoins: expiring,
+		Inventory:     inventory,
+		CoinHistory:   history,
 	}
 	return c.JSON(http.StatusOK, resp)
 }
@@ -86,12 +164,35 @@ func (h *Handler) SendCoin(c echo.Context) error {
 		return h.GetResponseError(c, cstErrors.UnauthorizedError)
 	}
 
-	if err := h.transactionService.SendCoin(c.Request().Context(), userId, req.ToUser, req.Amount); err != nil {
+	if err := h.transactionService.SendCoin(c.Request().Context(), userId, req.ToUser, req.Amount, req.Message, req.Category); err != nil {
 		return h.GetResponseError(c, err)
 	}
 	return c.NoContent(http.StatusOK)
 }
 
+func (h *Handler) BulkSendCoin(c echo.Context) error {
+	var req model.BulkTransferRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	userId, ok := c.Get(utils.UserIdCtxKey).(string)
+	if !ok {
+		return h.GetResponseError(c, cstErrors.UnauthorizedError)
+	}
+
+	resp, err := h.transactionService.BulkSendCoin(c.Request().Context(), userId, req.Transfers, req.Reason)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// validIds checks ids of merch and its optional variant, malformed ids would fail the uuid cast in Postgres
+func validIds(itemId, variantId string) bool {
+	return utils.IsValidUUID(itemId) && (variantId == "" || utils.IsValidUUID(variantId))
+}
+
 func (h *Handler) BuyItem(c echo.Context) error {
 	itemId := c.Param("item")
 	userId, ok := c.Get(utils.UserIdCtxKey).(string)
@@ -99,12 +200,463 @@ func (h *Handler) BuyItem(c echo.Context) error {
 		return h.GetResponseError(c, cstErrors.UnauthorizedError)
 	}
 
-	if err := h.transactionService.BuyItem(c.Request().Context(), userId, itemId); err != nil {
+	variantId := c.QueryParam("variant")
+	if !validIds(itemId, variantId) {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+	promoCode := c.QueryParam("promo")
+	var err error
+	if recipient := c.QueryParam("to"); recipient != "" {
+		err = h.transactionService.GiftItem(c.Request().Context(), userId, recipient, itemId, variantId, promoCode)
+	} else {
+		err = h.transactionService.BuyItem(c.Request().Context(), userId, itemId, variantId, promoCode)
+	}
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) GetPurchases(c echo.Context) error {
+	userId, ok := c.Get(utils.UserIdCtxKey).(string)
+	if !ok {
+		return h.GetResponseError(c, cstErrors.UnauthorizedError)
+	}
+
+	purchases, err := h.transactionService.GetPurchases(c.Request().Context(), userId)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	if purchases == nil {
+		purchases = []*model.PurchaseInfo{}
+	}
+	return c.JSON(http.StatusOK, purchases)
+}
+
+func (h *Handler) TransferItem(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+	var req model.TransferItemRequest
+	if err = c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	userId, ok := c.Get(utils.UserIdCtxKey).(string)
+	if !ok {
+		return h.GetResponseError(c, cstErrors.UnauthorizedError)
+	}
+
+	if err = h.transactionService.TransferItem(c.Request().Context(), userId, id, req.ToUser); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) GetLeaderboard(c echo.Context) error {
+	metric := c.QueryParam("metric")
+	if metric == "" {
+		metric = model.LeaderboardReceived
+	}
+	window := c.QueryParam("window")
+	if window == "" {
+		window = model.LeaderboardWindowWeek
+	}
+	limit := defaultLeaderboardLimit
+	if param := c.QueryParam("limit"); param != "" {
+		var err error
+		if limit, err = strconv.Atoi(param); err != nil {
+			return h.GetResponseError(c, cstErrors.BadRequestDataError)
+		}
+	}
+
+	leaderboard, err := h.leaderboardService.GetLeaderboard(c.Request().Context(), metric, window, limit)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusOK, leaderboard)
+}
+
+func (h *Handler) GetCart(c echo.Context) error {
+	userId, ok := c.Get(utils.UserIdCtxKey).(string)
+	if !ok {
+		return h.GetResponseError(c, cstErrors.UnauthorizedError)
+	}
+
+	cart, err := h.cartService.GetCart(c.Request().Context(), userId)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusOK, cart)
+}
+
+func (h *Handler) AddToCart(c echo.Context) error {
+	userId, ok := c.Get(utils.UserIdCtxKey).(string)
+	if !ok {
+		return h.GetResponseError(c, cstErrors.UnauthorizedError)
+	}
+	var req model.CartItemRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	if !validIds(req.Item, req.Variant) {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+	if err := h.cartService.AddItem(c.Request().Context(), userId, req.Item, req.Variant, req.Quantity); err != nil {
 		return h.GetResponseError(c, err)
 	}
 	return c.NoContent(http.StatusOK)
 }
 
+func (h *Handler) RemoveFromCart(c echo.Context) error {
+	userId, ok := c.Get(utils.UserIdCtxKey).(string)
+	if !ok {
+		return h.GetResponseError(c, cstErrors.UnauthorizedError)
+	}
+
+	itemId, variantId := c.Param("item"), c.QueryParam("variant")
+	if !validIds(itemId, variantId) {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+	if err := h.cartService.RemoveItem(c.Request().Context(), userId, itemId, variantId); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) Checkout(c echo.Context) error {
+	userId, ok := c.Get(utils.UserIdCtxKey).(string)
+	if !ok {
+		return h.GetResponseError(c, cstErrors.UnauthorizedError)
+	}
+
+	order, err := h.transactionService.Checkout(c.Request().Context(), userId)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusOK, order)
+}
+
+func (h *Handler) GetWishlist(c echo.Context) error {
+	userId, ok := c.Get(utils.UserIdCtxKey).(string)
+	if !ok {
+		return h.GetResponseError(c, cstErrors.UnauthorizedError)
+	}
+	ctx := c.Request().Context()
+
+	balance, err := h.userService.GetUserBalance(ctx, userId)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	wishlist, err := h.wishlistService.GetWishlist(ctx, userId, balance)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusOK, wishlist)
+}
+
+func (h *Handler) AddToWishlist(c echo.Context) error {
+	userId, ok := c.Get(utils.UserIdCtxKey).(string)
+	if !ok {
+		return h.GetResponseError(c, cstErrors.UnauthorizedError)
+	}
+	var req model.WishlistRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	if !validIds(req.Item, "") {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+	if err := h.wishlistService.AddItem(c.Request().Context(), userId, req.Item); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) RemoveFromWishlist(c echo.Context) error {
+	userId, ok := c.Get(utils.UserIdCtxKey).(string)
+	if !ok {
+		return h.GetResponseError(c, cstErrors.UnauthorizedError)
+	}
+
+	itemId := c.Param("item")
+	if !validIds(itemId, "") {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+	if err := h.wishlistService.RemoveItem(c.Request().Context(), userId, itemId); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) AdminGrantCoins(c echo.Context) error {
+	var req model.AdminCoinRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	user, err := h.userService.GetUserByUsername(ctx, req.User)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	if err = h.transactionService.GrantCoins(ctx, user.Id, req.Amount, req.Reason); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) AdminDeductCoins(c echo.Context) error {
+	var req model.AdminCoinRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	user, err := h.userService.GetUserByUsername(ctx, req.User)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	if err = h.transactionService.DeductCoins(ctx, user.Id, req.Amount, req.Reason); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) AdminBulkGrantCoins(c echo.Context) error {
+	var req model.BulkTransferRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	resp, err := h.transactionService.BulkSendCoin(c.Request().Context(), model.SystemUserId, req.Transfers, req.Reason)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) AdminReverseTransaction(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+	var req model.ReversalRequest
+	if err = c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	reversal, err := h.transactionService.ReverseTransaction(c.Request().Context(), id, req.Reason)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusOK, model.NewTransactionResponse(reversal))
+}
+
+func (h *Handler) AdminReversePurchase(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+	var req model.ReversalRequest
+	if err = c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	refund, err := h.transactionService.ReversePurchase(c.Request().Context(), id, req.Reason)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusOK, model.NewTransactionResponse(refund))
+}
+
+func (h *Handler) AdminChangeOrderStatus(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+	var req model.OrderStatusRequest
+	if err = c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	if err = h.transactionService.ChangeOrderStatus(c.Request().Context(), id, req.Status, req.Reason); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) AdminSetMerchStock(c echo.Context) error {
+	var req model.MerchStockRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	if err := h.merchService.SetStock(c.Request().Context(), c.Param("name"), req.Stock); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) AdminSetMerchLimit(c echo.Context) error {
+	var req model.MerchLimitRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	if err := h.merchService.SetMaxPerUser(c.Request().Context(), c.Param("name"), req.MaxPerUser); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) AdminSetMerchDetails(c echo.Context) error {
+	var req model.MerchDetailsRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	if err := h.merchService.SetDetails(c.Request().Context(), c.Param("name"), &req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) AdminAddMerchVariant(c echo.Context) error {
+	var req model.MerchVariantRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	variant, err := h.merchService.AddVariant(c.Request().Context(), c.Param("name"), &req)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusCreated, model.NewMerchVariantResponse(variant))
+}
+
+func (h *Handler) AdminSetVariantStock(c echo.Context) error {
+	var req model.MerchStockRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	variantId := c.Param("id")
+	if !utils.IsValidUUID(variantId) {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+	if err := h.merchService.SetVariantStock(c.Request().Context(), variantId, req.Stock); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) AdminRestockMerch(c echo.Context) error {
+	var req model.RestockRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	stock, err := h.merchService.Restock(c.Request().Context(), c.Param("name"), req.Quantity)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusOK, model.MerchStockRequest{Stock: &stock})
+}
+
+func (h *Handler) AdminLowStockReport(c echo.Context) error {
+	threshold := defaultLowStockThreshold
+	if param := c.QueryParam("threshold"); param != "" {
+		var err error
+		if threshold, err = strconv.Atoi(param); err != nil {
+			return h.GetResponseError(c, cstErrors.BadRequestDataError)
+		}
+	}
+
+	merchList, err := h.merchService.GetLowStock(c.Request().Context(), threshold)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	resp := make([]*model.MerchResponse, 0, len(merchList))
+	for _, m := range merchList {
+		resp = append(resp, model.NewMerchResponse(m))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) AdminCreatePriceSchedule(c echo.Context) error {
+	var req model.PriceScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	schedule, err := h.merchService.CreatePriceSchedule(c.Request().Context(), c.Param("name"), &req)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusCreated, model.NewPriceScheduleResponse(schedule))
+}
+
+func (h *Handler) AdminGetPriceSchedules(c echo.Context) error {
+	schedules, err := h.merchService.GetPriceSchedules(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	resp := make([]*model.PriceScheduleResponse, 0, len(schedules))
+	for _, s := range schedules {
+		resp = append(resp, model.NewPriceScheduleResponse(s))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) AdminDeletePriceSchedule(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return h.GetResponseError(c, cstErrors.BadRequestDataError)
+	}
+
+	if err = h.merchService.DeletePriceSchedule(c.Request().Context(), id); err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) AdminCreatePromoCode(c echo.Context) error {
+	var req model.PromoCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return h.GetResponseError(c, err)
+	}
+
+	promo, err := h.merchService.CreatePromoCode(c.Request().Context(), &req)
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusCreated, model.NewPromoCodeResponse(promo))
+}
+
+func (h *Handler) AdminGetPromoCodes(c echo.Context) error {
+	promos, err := h.merchService.GetPromoCodes(c.Request().Context())
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	resp := make([]*model.PromoCodeResponse, 0, len(promos))
+	for _, p := range promos {
+		resp = append(resp, model.NewPromoCodeResponse(p))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetCatalog(c echo.Context) error {
+	catalog, err := h.merchService.GetCatalog(c.Request().Context(), c.QueryParam("category"))
+	if err != nil {
+		return h.GetResponseError(c, err)
+	}
+	return c.JSON(http.StatusOK, catalog)
+}
+
 func (h *Handler) AuthHandler(c echo.Context) error {
 	var req model.AuthRequest
 	if err := c.Bind(&req); err != nil {
@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/config"
+	"log/slog"
+	"os"
+)
+
+type ctxKey struct{}
+
+// New creates a JSON logger writing to stdout at the configured level (debug, info, warn or error)
+func New(cfg config.LogConfig) (*slog.Logger, error) {
+	const op = "logger.New"
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})), nil
+}
+
+// WithContext returns ctx carrying the logger, request middleware use it to attach request fields
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger bound to ctx by WithContext or the default logger otherwise
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
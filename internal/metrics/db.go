@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dbStatsCollector exports connection pool statistics, they are read on every scrape
+type dbStatsCollector struct {
+	stats func() *pgxpool.Stat
+
+	maxConns        *prometheus.Desc
+	open            *prometheus.Desc
+	inUse           *prometheus.Desc
+	idle            *prometheus.Desc
+	acquireCount    *prometheus.Desc
+	acquireDuration *prometheus.Desc
+	emptyAcquire    *prometheus.Desc
+	canceledAcquire *prometheus.Desc
+	closed          *prometheus.Desc
+}
+
+// RegisterDBStats registers pool statistics reported by stats, e.g. (*pgxpool.Pool).Stat
+func RegisterDBStats(stats func() *pgxpool.Stat) {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db", name), help, nil, nil)
+	}
+	prometheus.MustRegister(&dbStatsCollector{
+		stats:           stats,
+		maxConns:        desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:            desc("open_connections", "Established connections both in use and idle."),
+		inUse:           desc("in_use_connections", "Connections currently in use."),
+		idle:            desc("idle_connections", "Idle connections."),
+		acquireCount:    desc("acquire_total", "Connections acquired from the pool."),
+		acquireDuration: desc("acquire_duration_seconds_total", "Time spent acquiring connections from the pool."),
+		emptyAcquire:    desc("wait_count_total", "Acquires that waited for a connection because the pool was empty."),
+		canceledAcquire: desc("canceled_acquire_total", "Acquires cancelled by the context."),
+		closed: prometheus.NewDesc(prometheus.BuildFQName(namespace, "db", "closed_connections_total"),
+			"Connections closed by the pool, by reason.", []string{"reason"}, nil),
+	})
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxConns
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquire
+	ch <- c.canceledAcquire
+	ch <- c.closed
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquire, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquire, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.closed, prometheus.CounterValue, float64(s.MaxIdleDestroyCount()), "max_idle_time")
+	ch <- prometheus.MustNewConstMetric(c.closed, prometheus.CounterValue, float64(s.MaxLifetimeDestroyCount()), "max_lifetime")
+}
@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"context"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/logger"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/utils"
 	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
+	"log/slog"
 	"net/http"
 	"strings"
 )
@@ -13,7 +16,10 @@ import (
 func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		user := ctx.Get("user")
-		invalidAuthError := model.ErrorResponse{Errors: "invalid token"}
+		invalidAuthError := model.ErrorResponse{
+			Errors:    "invalid token",
+			RequestId: ctx.Response().Header().Get(echo.HeaderXRequestID),
+		}
 		if user == nil {
 			return ctx.JSON(http.StatusUnauthorized, invalidAuthError)
 		}
@@ -34,11 +40,34 @@ func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		}
 
 		ctx.Set(utils.UserIdCtxKey, userId)
+		req := ctx.Request()
+		log := logger.FromContext(req.Context()).With(slog.String("user_id", userId))
+		ctx.SetRequest(req.WithContext(logger.WithContext(req.Context(), log)))
 
 		return next(ctx)
 	}
 }
 
+// AdminMiddleware must run after AuthMiddleware, it rejects users for whom isAdmin reports false
+func AdminMiddleware(isAdmin func(ctx context.Context, userId string) (bool, error)) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			requestId := ctx.Response().Header().Get(echo.HeaderXRequestID)
+			userId, ok := ctx.Get(utils.UserIdCtxKey).(string)
+			if !ok {
+				return ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Errors: "invalid token", RequestId: requestId})
+			}
+
+			admin, err := isAdmin(ctx.Request().Context(), userId)
+			if err != nil || !admin {
+				return ctx.JSON(http.StatusForbidden, model.ErrorResponse{Errors: "admin rights required", RequestId: requestId})
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
 func JWTMiddleware(secret string) echo.MiddlewareFunc {
 	return echojwt.WithConfig(echojwt.Config{
 		SigningKey:  []byte(secret),
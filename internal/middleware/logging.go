@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/logger"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/utils"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"time"
+)
+
+const maxRequestIdLength = 128
+
+// RequestIdMiddleware takes the request id from the X-Request-Id header or generates a new one,
+// echoes it in the response header and binds a logger with the request_id field to the request context
+func RequestIdMiddleware(base *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			requestId := req.Header.Get(echo.HeaderXRequestID)
+			if requestId == "" || len(requestId) > maxRequestIdLength {
+				var err error
+				if requestId, err = utils.GenerateUUID(); err != nil {
+					return err
+				}
+			}
+			ctx.Response().Header().Set(echo.HeaderXRequestID, requestId)
+
+			log := base.With(slog.String("request_id", requestId))
+			if sc := trace.SpanContextFromContext(req.Context()); sc.HasTraceID() {
+				log = log.With(slog.String("trace_id", sc.TraceID().String()))
+			}
+			ctx.SetRequest(req.WithContext(logger.WithContext(req.Context(), log)))
+			return next(ctx)
+		}
+	}
+}
+
+// RequestLoggerMiddleware must run after RequestIdMiddleware, it logs every request when it is done
+// with the fields bound to the request context, including user_id set by AuthMiddleware
+func RequestLoggerMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		start := time.Now()
+		err := next(ctx)
+
+		req := ctx.Request()
+		status := responseStatus(ctx, err)
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		logger.FromContext(req.Context()).LogAttrs(req.Context(), level, "request",
+			slog.String("method", req.Method),
+			slog.String("uri", req.RequestURI),
+			slog.String("route", ctx.Path()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("remote_ip", ctx.RealIP()),
+		)
+		return err
+	}
+}
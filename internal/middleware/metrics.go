@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/metrics"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MetricsMiddleware counts requests and observes their latency by the route pattern,
+// so that path parameters do not blow up the number of series
+func MetricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		start := time.Now()
+		err := next(ctx)
+
+		route := ctx.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := responseStatus(ctx, err)
+
+		method := ctx.Request().Method
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// responseStatus returns the status the error handler will respond with when the handler failed
+func responseStatus(ctx echo.Context, err error) int {
+	if err == nil {
+		return ctx.Response().Status
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return http.StatusInternalServerError
+}
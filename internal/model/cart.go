@@ -0,0 +1,7 @@
+package model
+
+type CartItem struct {
+	Merch    *Merch
+	Variant  *MerchVariant
+	Quantity int
+}
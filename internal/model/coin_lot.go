@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+const (
+	AllowancePeriodWeek  = "week"
+	AllowancePeriodMonth = "month"
+)
+
+func IsValidAllowancePeriod(period string) bool {
+	switch period {
+	case AllowancePeriodWeek, AllowancePeriodMonth:
+		return true
+	}
+	return false
+}
+
+// AllowancePeriod returns bounds of the allowance period containing now, weeks start on Monday.
+// Periods are computed in UTC so that all instances agree on them.
+func AllowancePeriod(now time.Time, period string) (start, end time.Time) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if period == AllowancePeriodWeek {
+		start = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 7)
+	}
+	start = day.AddDate(0, 0, 1-day.Day())
+	return start, start.AddDate(0, 1, 0)
+}
+
+// ExpiringCoins is the unspent part of a coin lot that expires at ExpiresAt
+type ExpiringCoins struct {
+	Amount    int       `json:"amount"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,23 @@
+package model
+
+const (
+	HealthStatusUp           = "up"
+	HealthStatusDown         = "down"
+	HealthStatusReady        = "ready"
+	HealthStatusNotReady     = "not_ready"
+	HealthStatusShuttingDown = "shutting_down"
+)
+
+type DependencyStatus struct {
+	Status string `json:"status"`
+}
+
+type ReadinessResponse struct {
+	Status       string                       `json:"status"`
+	Dependencies map[string]*DependencyStatus `json:"dependencies"`
+}
+
+// Ready reports whether the app can serve requests
+func (r *ReadinessResponse) Ready() bool {
+	return r.Status == HealthStatusReady
+}
@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+const (
+	LeaderboardReceived = "received" // coins received from colleagues
+	LeaderboardSent     = "sent"     // coins sent to colleagues
+	LeaderboardThanked  = "thanked"  // distinct colleagues coins were sent to
+	LeaderboardSpent    = "spent"    // coins spent on merch
+)
+
+const (
+	LeaderboardWindowWeek  = "week"
+	LeaderboardWindowMonth = "month"
+	LeaderboardWindowAll   = "all"
+)
+
+func IsValidLeaderboardMetric(metric string) bool {
+	switch metric {
+	case LeaderboardReceived, LeaderboardSent, LeaderboardThanked, LeaderboardSpent:
+		return true
+	}
+	return false
+}
+
+func IsValidLeaderboardWindow(window string) bool {
+	switch window {
+	case LeaderboardWindowWeek, LeaderboardWindowMonth, LeaderboardWindowAll:
+		return true
+	}
+	return false
+}
+
+// LeaderboardSince returns the start of the calendar week or month containing now,
+// nil means the all-time window
+func LeaderboardSince(now time.Time, window string) *time.Time {
+	switch window {
+	case LeaderboardWindowWeek:
+		start, _ := AllowancePeriod(now, AllowancePeriodWeek)
+		return &start
+	case LeaderboardWindowMonth:
+		start, _ := AllowancePeriod(now, AllowancePeriodMonth)
+		return &start
+	}
+	return nil
+}
+
+type LeaderboardEntry struct {
+	Rank  int    `json:"rank"`
+	User  string `json:"user"`
+	Value int    `json:"value"`
+}
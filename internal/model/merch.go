@@ -3,9 +3,68 @@ package model
 import "time"
 
 type Merch struct {
+	Id          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Category    string         `json:"category"`
+	ImageURL    string         `json:"image_url"`
+	Price       int            `json:"price"`
+	IsSelling   bool           `json:"is_selling"`
+	Stock       *int           `json:"stock"`
+	MaxPerUser  *int           `json:"max_per_user"`
+	HasVariants bool           `json:"has_variants"`
+	Sale        *PriceSchedule `json:"sale,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// MerchVariant is a size or color of the merch. Merch with variants is bought
+// by variant and its stock is tracked per variant.
+type MerchVariant struct {
 	Id        string    `json:"id"`
-	Name      string    `json:"name"`
-	Price     int       `json:"price"`
-	IsSelling bool      `json:"is_selling"`
+	MerchId   string    `json:"merch_id"`
+	Size      string    `json:"size"`
+	Color     string    `json:"color"`
+	Stock     *int      `json:"stock"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// Label is a human-readable name of the variant, e.g. "XL / black"
+func (v *MerchVariant) Label() string {
+	switch {
+	case v.Size == "":
+		return v.Color
+	case v.Color == "":
+		return v.Size
+	}
+	return v.Size + " / " + v.Color
+}
+
+// EffectivePrice is the price with the currently active schedule applied
+func (m *Merch) EffectivePrice() int {
+	if m.Sale == nil {
+		return m.Price
+	}
+	return m.Sale.Apply(m.Price)
+}
+
+type PriceSchedule struct {
+	Id         int64     `json:"id"`
+	MerchId    string    `json:"merch_id"`
+	StartsAt   time.Time `json:"starts_at"`
+	EndsAt     time.Time `json:"ends_at"`
+	SalePrice  *int      `json:"sale_price,omitempty"`
+	PercentOff *int      `json:"percent_off,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Apply returns the scheduled price for an item with the given base price, never less than one coin
+// and never more than the base price
+func (s *PriceSchedule) Apply(price int) int {
+	if s.SalePrice != nil {
+		return min(*s.SalePrice, price)
+	}
+	if s.PercentOff != nil {
+		price = price * (100 - *s.PercentOff) / 100
+	}
+	return max(price, 1)
+}
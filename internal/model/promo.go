@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+const (
+	PromoDiscountPercent = "percent"
+	PromoDiscountFixed   = "fixed"
+)
+
+type PromoCode struct {
+	Id             int64      `json:"id"`
+	Code           string     `json:"code"`
+	DiscountType   string     `json:"discount_type"`
+	DiscountValue  int        `json:"discount_value"`
+	MaxUses        *int       `json:"max_uses"`
+	MaxUsesPerUser *int       `json:"max_uses_per_user"`
+	Uses           int        `json:"uses"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	MerchIds       []string   `json:"merch_ids"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// AppliesTo reports whether the code can be used for the merch, a code without merch list fits any item
+func (p *PromoCode) AppliesTo(merchId string) bool {
+	if len(p.MerchIds) == 0 {
+		return true
+	}
+	for _, id := range p.MerchIds {
+		if id == merchId {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PromoCode) IsExpired(now time.Time) bool {
+	return p.ExpiresAt != nil && !now.Before(*p.ExpiresAt)
+}
+
+// Apply returns the discounted price, never less than one coin
+func (p *PromoCode) Apply(price int) int {
+	switch p.DiscountType {
+	case PromoDiscountPercent:
+		price = price * (100 - p.DiscountValue) / 100
+	case PromoDiscountFixed:
+		price -= p.DiscountValue
+	}
+	return max(price, 1)
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+const (
+	OrderStatusPlaced         = "placed"
+	OrderStatusReadyForPickup = "ready_for_pickup"
+	OrderStatusDelivered      = "delivered"
+	OrderStatusCancelled      = "cancelled"
+)
+
+// orderTransitions lists statuses an order may move to from the given one
+var orderTransitions = map[string][]string{
+	OrderStatusPlaced:         {OrderStatusReadyForPickup, OrderStatusCancelled},
+	OrderStatusReadyForPickup: {OrderStatusDelivered, OrderStatusCancelled},
+}
+
+func IsValidOrderStatus(status string) bool {
+	switch status {
+	case OrderStatusPlaced, OrderStatusReadyForPickup, OrderStatusDelivered, OrderStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func CanChangeOrderStatus(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+type Purchase struct {
+	Id         int64      `json:"id"`
+	UserId     string     `json:"user_id"`
+	BuyerId    string     `json:"buyer_id"`
+	MerchId    string     `json:"merch_id"`
+	VariantId  string     `json:"variant_id,omitempty"`
+	Price      int        `json:"price"`
+	Status     string     `json:"status"`
+	ReversedAt *time.Time `json:"reversed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
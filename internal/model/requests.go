@@ -1,11 +1,95 @@
 package model
 
+import "time"
+
 type AuthRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
 type SendCoinRequest struct {
+	ToUser   string `json:"toUser"`
+	Amount   int    `json:"amount"`
+	Message  string `json:"message"`
+	Category string `json:"category"`
+}
+
+type AdminCoinRequest struct {
+	User   string `json:"user"`
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+type MerchStockRequest struct {
+	Stock *int `json:"stock"`
+}
+
+type MerchLimitRequest struct {
+	MaxPerUser *int `json:"maxPerUser"`
+}
+
+type PriceScheduleRequest struct {
+	StartsAt   time.Time `json:"startsAt"`
+	EndsAt     time.Time `json:"endsAt"`
+	SalePrice  *int      `json:"salePrice"`
+	PercentOff *int      `json:"percentOff"`
+}
+
+type PromoCodeRequest struct {
+	Code           string     `json:"code"`
+	DiscountType   string     `json:"discountType"`
+	DiscountValue  int        `json:"discountValue"`
+	MaxUses        *int       `json:"maxUses"`
+	MaxUsesPerUser *int       `json:"maxUsesPerUser"`
+	ExpiresAt      *time.Time `json:"expiresAt"`
+	Merch          []string   `json:"merch"`
+}
+
+type CartItemRequest struct {
+	Item     string `json:"item"`
+	Variant  string `json:"variant"`
+	Quantity int    `json:"quantity"`
+}
+
+type WishlistRequest struct {
+	Item string `json:"item"`
+}
+
+type MerchDetailsRequest struct {
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	ImageURL    string `json:"imageUrl"`
+}
+
+type MerchVariantRequest struct {
+	Size  string `json:"size"`
+	Color string `json:"color"`
+	Stock *int   `json:"stock"`
+}
+
+type RestockRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+type OrderStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type TransferItemRequest struct {
+	ToUser string `json:"toUser"`
+}
+
+type ReversalRequest struct {
+	Reason string `json:"reason"`
+}
+
+type BulkTransferRequest struct {
+	Reason    string              `json:"reason"`
+	Transfers []*BulkTransferItem `json:"transfers"`
+}
+
+type BulkTransferItem struct {
 	ToUser string `json:"toUser"`
 	Amount int    `json:"amount"`
 }
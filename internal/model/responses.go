@@ -1,21 +1,102 @@
 package model
 
+import "time"
+
 type InfoResponse struct {
-	Balance     int              `json:"coins"`
-	Inventory   []*InfoInventory `json:"inventory"`
-	CoinHistory *CoinHistory     `json:"coinHistory"`
+	Balance       int              `json:"coins"`
+	ExpiringCoins []*ExpiringCoins `json:"expiringCoins,omitempty"`
+	Inventory     []*InfoInventory `json:"inventory"`
+	CoinHistory   *CoinHistory     `json:"coinHistory"`
 }
 
 type AuthResponse struct {
 	Token string `json:"token"`
 }
 
+type CatalogItem struct {
+	Id             string            `json:"id"`
+	Name           string            `json:"name"`
+	Description    string            `json:"description,omitempty"`
+	Category       string            `json:"category,omitempty"`
+	ImageURL       string            `json:"imageUrl,omitempty"`
+	Price          int               `json:"price"`
+	EffectivePrice int               `json:"effectivePrice"`
+	Stock          *int              `json:"stock,omitempty"`
+	SaleEndsAt     *time.Time        `json:"saleEndsAt,omitempty"`
+	Variants       []*CatalogVariant `json:"variants,omitempty"`
+}
+
+type CatalogVariant struct {
+	Id    string `json:"id"`
+	Size  string `json:"size,omitempty"`
+	Color string `json:"color,omitempty"`
+	Stock *int   `json:"stock,omitempty"`
+}
+
+type CartResponse struct {
+	Items []*CartLine `json:"items"`
+	Total int         `json:"total"`
+}
+
+type CartLine struct {
+	Item      string `json:"item"`
+	Name      string `json:"name"`
+	Variant   string `json:"variant,omitempty"`
+	VariantId string `json:"variantId,omitempty"`
+	Quantity  int    `json:"quantity"`
+	Price     int    `json:"price"`
+	LineTotal int    `json:"lineTotal"`
+}
+
+type WishlistItem struct {
+	Item        string    `json:"item"`
+	Name        string    `json:"name"`
+	Price       int       `json:"price"`
+	IsSelling   bool      `json:"isSelling"`
+	CoinsNeeded int       `json:"coinsNeeded"`
+	AddedAt     time.Time `json:"addedAt"`
+}
+
+type PurchaseInfo struct {
+	Id              int64     `json:"id"`
+	Item            string    `json:"item"`
+	Variant         string    `json:"variant,omitempty"`
+	GiftFrom        string    `json:"giftFrom,omitempty"`
+	GiftTo          string    `json:"giftTo,omitempty"`
+	TransferredFrom string    `json:"transferredFrom,omitempty"`
+	TransferredTo   string    `json:"transferredTo,omitempty"`
+	Price           int       `json:"price"`
+	Status          string    `json:"status"`
+	StatusUpdatedAt time.Time `json:"statusUpdatedAt"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+type LeaderboardResponse struct {
+	Metric  string              `json:"metric"`
+	Window  string              `json:"window"`
+	Since   *time.Time          `json:"since,omitempty"`
+	Entries []*LeaderboardEntry `json:"entries"`
+}
+
+type BulkTransferResponse struct {
+	BatchId    string `json:"batchId"`
+	Recipients int    `json:"recipients"`
+	Total      int    `json:"total"`
+}
+
 type ErrorResponse struct {
-	Errors string `json:"errors"`
+	Errors    string `json:"errors"`
+	RequestId string `json:"requestId,omitempty"`
 }
 
 type InfoInventory struct {
-	Type     string `json:"type"`
+	Type     string              `json:"type"`
+	Quantity int                 `json:"quantity"`
+	Variants []*InventoryVariant `json:"variants,omitempty"`
+}
+
+type InventoryVariant struct {
+	Variant  string `json:"variant"`
 	Quantity int    `json:"quantity"`
 }
 
@@ -25,11 +106,149 @@ type CoinHistory struct {
 }
 
 type ReceivedCoin struct {
+	Id       int64  `json:"id"`
 	FromUser string `json:"fromUser"`
 	Amount   int    `json:"amount"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Category string `json:"category,omitempty"`
+	Reversed bool   `json:"reversed,omitempty"`
 }
 
 type SentCoin struct {
-	ToUser string `json:"toUser"`
-	Amount int    `json:"amount"`
+	Id       int64  `json:"id"`
+	ToUser   string `json:"toUser"`
+	Amount   int    `json:"amount"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Category string `json:"category,omitempty"`
+	Reversed bool   `json:"reversed,omitempty"`
+}
+
+type MerchResponse struct {
+	Id         string    `json:"id"`
+	Name       string    `json:"name"`
+	Price      int       `json:"price"`
+	IsSelling  bool      `json:"isSelling"`
+	Stock      *int      `json:"stock"`
+	MaxPerUser *int      `json:"maxPerUser"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func NewMerchResponse(m *Merch) *MerchResponse {
+	return &MerchResponse{
+		Id:         m.Id,
+		Name:       m.Name,
+		Price:      m.Price,
+		IsSelling:  m.IsSelling,
+		Stock:      m.Stock,
+		MaxPerUser: m.MaxPerUser,
+		CreatedAt:  m.CreatedAt,
+	}
+}
+
+type MerchVariantResponse struct {
+	Id        string    `json:"id"`
+	MerchId   string    `json:"merchId"`
+	Size      string    `json:"size,omitempty"`
+	Color     string    `json:"color,omitempty"`
+	Stock     *int      `json:"stock"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func NewMerchVariantResponse(v *MerchVariant) *MerchVariantResponse {
+	return &MerchVariantResponse{
+		Id:        v.Id,
+		MerchId:   v.MerchId,
+		Size:      v.Size,
+		Color:     v.Color,
+		Stock:     v.Stock,
+		CreatedAt: v.CreatedAt,
+	}
+}
+
+type PriceScheduleResponse struct {
+	Id         int64     `json:"id"`
+	MerchId    string    `json:"merchId"`
+	StartsAt   time.Time `json:"startsAt"`
+	EndsAt     time.Time `json:"endsAt"`
+	SalePrice  *int      `json:"salePrice,omitempty"`
+	PercentOff *int      `json:"percentOff,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func NewPriceScheduleResponse(s *PriceSchedule) *PriceScheduleResponse {
+	return &PriceScheduleResponse{
+		Id:         s.Id,
+		MerchId:    s.MerchId,
+		StartsAt:   s.StartsAt,
+		EndsAt:     s.EndsAt,
+		SalePrice:  s.SalePrice,
+		PercentOff: s.PercentOff,
+		CreatedAt:  s.CreatedAt,
+	}
+}
+
+type PromoCodeResponse struct {
+	Id             int64      `json:"id"`
+	Code           string     `json:"code"`
+	DiscountType   string     `json:"discountType"`
+	DiscountValue  int        `json:"discountValue"`
+	MaxUses        *int       `json:"maxUses"`
+	MaxUsesPerUser *int       `json:"maxUsesPerUser"`
+	Uses           int        `json:"uses"`
+	ExpiresAt      *time.Time `json:"expiresAt"`
+	Merch          []string   `json:"merch"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func NewPromoCodeResponse(p *PromoCode) *PromoCodeResponse {
+	merch := p.MerchIds
+	if merch == nil {
+		merch = []string{}
+	}
+	return &PromoCodeResponse{
+		Id:             p.Id,
+		Code:           p.Code,
+		DiscountType:   p.DiscountType,
+		DiscountValue:  p.DiscountValue,
+		MaxUses:        p.MaxUses,
+		MaxUsesPerUser: p.MaxUsesPerUser,
+		Uses:           p.Uses,
+		ExpiresAt:      p.ExpiresAt,
+		Merch:          merch,
+		CreatedAt:      p.CreatedAt,
+	}
+}
+
+type TransactionResponse struct {
+	Id         int64      `json:"id"`
+	FromUserId string     `json:"fromUserId"`
+	ToUserId   string     `json:"toUserId"`
+	Amount     int        `json:"amount"`
+	Reason     string     `json:"reason,omitempty"`
+	BatchId    string     `json:"batchId,omitempty"`
+	Message    string     `json:"message,omitempty"`
+	Category   string     `json:"category,omitempty"`
+	ReversalOf int64      `json:"reversalOf,omitempty"`
+	PurchaseId int64      `json:"purchaseId,omitempty"`
+	ReversedAt *time.Time `json:"reversedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func NewTransactionResponse(t *Transaction) *TransactionResponse {
+	return &TransactionResponse{
+		Id:         t.Id,
+		FromUserId: t.FromUserId,
+		ToUserId:   t.ToUserId,
+		Amount:     t.Amount,
+		Reason:     t.Reason,
+		BatchId:    t.BatchId,
+		Message:    t.Message,
+		Category:   t.Category,
+		ReversalOf: t.ReversalOf,
+		PurchaseId: t.PurchaseId,
+		ReversedAt: t.ReversedAt,
+		CreatedAt:  t.CreatedAt,
+	}
 }
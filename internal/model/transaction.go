@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+const (
+	TransferCategoryKudos   = "kudos"
+	TransferCategoryPayback = "payback"
+	TransferCategoryGift    = "gift"
+)
+
+const MaxTransferMessageLength = 255
+
+// Kinds of transactions made by the coin lot scheduler, other transactions have no kind
+const (
+	TransactionKindAllowance = "allowance"
+	TransactionKindExpiry    = "expiry"
+)
+
+func IsValidTransferCategory(category string) bool {
+	switch category {
+	case TransferCategoryKudos, TransferCategoryPayback, TransferCategoryGift:
+		return true
+	}
+	return false
+}
+
+type Transaction struct {
+	Id         int64      `json:"id"`
+	FromUserId string     `json:"from_user_id"`
+	ToUserId   string     `json:"to_user_id"`
+	Amount     int        `json:"amount"`
+	Reason     string     `json:"reason"`
+	BatchId    string     `json:"batch_id"`
+	Message    string     `json:"message"`
+	Category   string     `json:"category"`
+	ReversalOf int64      `json:"reversal_of,omitempty"`
+	PurchaseId int64      `json:"purchase_id,omitempty"`
+	Kind       string     `json:"kind,omitempty"`
+	ReversedAt *time.Time `json:"reversed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
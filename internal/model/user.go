@@ -2,10 +2,14 @@ package model
 
 import "time"
 
+// SystemUserId is the account used as the counterparty of administrative coin operations
+const SystemUserId = "00000000-0000-0000-0000-000000000000"
+
 type User struct {
 	Id        string    `json:"id"`
 	Username  string    `json:"username"`
 	Password  string    `json:"password"`
 	Balance   int       `json:"balance"`
+	IsAdmin   bool      `json:"is_admin"`
 	CreatedAt time.Time `json:"created_at"`
 }
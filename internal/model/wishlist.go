@@ -0,0 +1,8 @@
+package model
+
+import "time"
+
+type WishlistEntry struct {
+	Merch   *Merch
+	AddedAt time.Time
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+)
+
+// AddToCart puts the merch into the cart or increases its quantity if it is already there
+func (r *PostgresRepository) AddToCart(ctx context.Context, userId, merchId, variantId string, quantity int) error {
+	const op = "postgres.AddToCart"
+	const query = `INSERT INTO cart_items(user_id, merch_id, variant_id, quantity)
+					VALUES ($1, $2, NULLIF($3, '')::uuid, $4)
+					ON CONFLICT (user_id, merch_id, variant_id) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, userId, merchId, variantId, quantity); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RemoveFromCart(ctx context.Context, userId, merchId, variantId string) error {
+	const op = "postgres.RemoveFromCart"
+	const query = `DELETE FROM cart_items
+					WHERE user_id = $1 AND merch_id = $2 AND variant_id IS NOT DISTINCT FROM NULLIF($3, '')::uuid;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, userId, merchId, variantId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ClearCart(ctx context.Context, userId string) error {
+	const op = "postgres.ClearCart"
+	const query = `DELETE FROM cart_items WHERE user_id = $1;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetCartItems returns the cart lines with merch and its active price schedule.
+// Within a transaction the lines are locked, so the cart cannot change until checkout ends.
+func (r *PostgresRepository) GetCartItems(ctx context.Context, userId string) ([]*model.CartItem, error) {
+	const op = "postgres.GetCartItems"
+	const query = `SELECT c.quantity, m.id, m.name, m.price, m.is_selling, m.stock, m.max_per_user,
+					` + hasVariantsQuery + `, m.created_at,
+					v.id, v.size, v.color, v.stock,
+					s.id, s.starts_at, s.ends_at, s.sale_price, s.percent_off
+					FROM cart_items c
+					JOIN merch m ON m.id = c.merch_id
+					LEFT JOIN merch_variants v ON v.id = c.variant_id
+					LEFT JOIN LATERAL (` + activePriceScheduleQuery + `) s ON true
+					WHERE c.user_id = $1
+					ORDER BY c.created_at
+					FOR UPDATE OF c`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	rows, err := r.conn(ctx).Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var items []*model.CartItem
+	for rows.Next() {
+		var (
+			item                   = model.CartItem{Merch: &model.Merch{}}
+			m                      = item.Merch
+			variantId, size, color sql.NullString
+			variantStock           *int
+			sale                   nullPriceSchedule
+		)
+		if err = rows.Scan(&item.Quantity, &m.Id, &m.Name, &m.Price, &m.IsSelling, &m.Stock, &m.MaxPerUser, &m.HasVariants, &m.CreatedAt,
+			&variantId, &size, &color, &variantStock,
+			&sale.id, &sale.startsAt, &sale.endsAt, &sale.salePrice, &sale.percentOff); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		m.Sale = sale.toModel(m.Id)
+		if variantId.Valid {
+			item.Variant = &model.MerchVariant{
+				Id:      variantId.String,
+				MerchId: m.Id,
+				Size:    size.String,
+				Color:   color.String,
+				Stock:   variantStock,
+			}
+		}
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return items, nil
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"time"
+)
+
+// takeCoinLotsCTE selects $2 coins of the user $1 from lots in FIFO order of expiry as taken(id, expires_at, amount).
+// It runs after the balance update, which keeps the user row locked, and the balance is never less
+// than the coins left in lots, so the rest is taken from non-expiring coins.
+const takeCoinLotsCTE = `WITH lots AS (
+						SELECT id, remaining, expires_at FROM coin_lots
+						WHERE user_id = $1 AND remaining > 0
+						FOR UPDATE
+					), ordered AS (
+						SELECT id, remaining, expires_at, SUM(remaining) OVER (ORDER BY expires_at, id) - remaining AS spent_before
+						FROM lots
+					), taken AS (
+						SELECT id, expires_at, LEAST(remaining, $2 - spent_before) AS amount
+						FROM ordered
+						WHERE spent_before < $2
+					)`
+
+// consumeCoinLotsQuery takes $2 spent coins from the user's lots, spent coins leave the lots for good
+const consumeCoinLotsQuery = takeCoinLotsCTE + `
+					UPDATE coin_lots c
+					SET remaining = c.remaining - t.amount
+					FROM taken t
+					WHERE c.id = t.id;`
+
+// moveCoinLotsQuery takes $2 transferred coins from the user's lots and gives them to the recipient $3
+// as lots with the same expiry, so transfers can't be used to keep coins from expiring
+const moveCoinLotsQuery = takeCoinLotsCTE + `, consumed AS (
+						UPDATE coin_lots c
+						SET remaining = c.remaining - t.amount
+						FROM taken t
+						WHERE c.id = t.id
+					)
+					INSERT INTO coin_lots(user_id, amount, remaining, expires_at)
+					SELECT $3::uuid, amount, amount, expires_at FROM taken;`
+
+// IssueAllowance credits the allowance to every user except the system account and logs it
+// as a transaction from the system account. Users who already got the allowance for the period
+// are skipped, so it is safe to run repeatedly and from several instances. Returns the number
+// of credited users.
+func (r *PostgresRepository) IssueAllowance(ctx context.Context, amount int, periodStart, expiresAt time.Time, reason string) (int, error) {
+	const op = "postgres.IssueAllowance"
+	// Users are locked before the balance update in the same order as LockUsers does to avoid deadlocks
+	const lockQuery = `SELECT id FROM users
+					WHERE id <> $1
+					AND id NOT IN (SELECT user_id FROM coin_lots WHERE allowance_period = $2)
+					ORDER BY id
+					FOR UPDATE;`
+	const query = `WITH issued AS (
+						INSERT INTO coin_lots(user_id, amount, remaining, allowance_period, expires_at)
+						SELECT id, $1, $1, $2, $3 FROM users WHERE id <> $4
+						ON CONFLICT (user_id, allowance_period) DO NOTHING
+						RETURNING user_id, amount
+					), credited AS (
+						UPDATE users u SET balance = u.balance + i.amount
+						FROM issued i WHERE u.id = i.user_id
+					)
+					INSERT INTO transactions(from_user_id, to_user_id, amount, reason, kind)
+					SELECT $4, user_id, amount, $5, $6 FROM issued;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var (
+		issued int
+		batch  pgx.Batch
+	)
+	batch.Queue(lockQuery, model.SystemUserId, periodStart)
+	batch.Queue(query, amount, periodStart, expiresAt, model.SystemUserId, reason, model.TransactionKindAllowance).
+		Exec(func(res pgconn.CommandTag) error {
+			issued = int(res.RowsAffected())
+			return nil
+		})
+	err := r.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := r.sendBatch(ctx, &batch); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return issued, nil
+}
+
+// ExpireCoinLots removes unspent coins of lots expired by now from balances and logs them
+// as transactions to the system account. Returns the number of expired coins.
+func (r *PostgresRepository) ExpireCoinLots(ctx context.Context, now time.Time, reason string) (int, error) {
+	const op = "postgres.ExpireCoinLots"
+	// Users are locked before lots in the same order as LockUsers does to avoid deadlocks
+	const lockQuery = `SELECT id FROM users
+					WHERE id IN (SELECT user_id FROM coin_lots WHERE remaining > 0 AND expires_at <= $1)
+					ORDER BY id
+					FOR UPDATE;`
+	const query = `WITH expired AS (
+						SELECT id, user_id, remaining FROM coin_lots
+						WHERE remaining > 0 AND expires_at <= $1
+						FOR UPDATE
+					), emptied AS (
+						UPDATE coin_lots c SET remaining = 0
+						FROM expired e WHERE c.id = e.id
+					), per_user AS (
+						SELECT user_id, SUM(remaining) AS amount FROM expired GROUP BY user_id
+					), debited AS (
+						UPDATE users u SET balance = u.balance - p.amount
+						FROM per_user p WHERE u.id = p.user_id
+					), logged AS (
+						INSERT INTO transactions(from_user_id, to_user_id, amount, reason, kind)
+						SELECT user_id, $2, amount, $3, $4 FROM per_user
+					)
+					SELECT COALESCE(SUM(amount), 0) FROM per_user;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var (
+		expired int
+		batch   pgx.Batch
+	)
+	batch.Queue(lockQuery, now)
+	batch.Queue(query, now, model.SystemUserId, reason, model.TransactionKindExpiry).QueryRow(func(row pgx.Row) error {
+		return row.Scan(&expired)
+	})
+	// Both queries go in one round trip, the lock is taken before the update
+	err := r.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := r.sendBatch(ctx, &batch); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
+
+// GetExpiringCoins returns unspent coins of the user grouped by expiry date, soonest first
+func (r *PostgresRepository) GetExpiringCoins(ctx context.Context, userId string) ([]*model.ExpiringCoins, error) {
+	const op = "postgres.GetExpiringCoins"
+	const query = `SELECT SUM(remaining), expires_at
+					FROM coin_lots
+					WHERE user_id = $1 AND remaining > 0
+					GROUP BY expires_at
+					ORDER BY expires_at`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	rows, err := r.conn(ctx).Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var coins []*model.ExpiringCoins
+	for rows.Next() {
+		var c model.ExpiringCoins
+		if err = rows.Scan(&c.Amount, &c.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		coins = append(coins, &c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return coins, nil
+}
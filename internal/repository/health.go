@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"strings"
+)
+
+// schemaTables are created by migrations/init.sql, the schema is up to date when all of them exist
+var schemaTables = []string{
+	"users",
+	"transactions",
+	"merch",
+	"merch_variants",
+	"price_schedules",
+	"purchases",
+	"cart_items",
+	"wishlist_items",
+	"promo_codes",
+	"promo_code_merch",
+	"promo_redemptions",
+	"coin_lots",
+	"item_transfers",
+}
+
+func (r *PostgresRepository) Ping(ctx context.Context) error {
+	const op = "postgres.Ping"
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if err := r.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// schemaColumns were added to tables of earlier releases, the tables exist in databases
+// migrated partially, so the columns are checked too
+var schemaColumns = []string{
+	"users.is_admin",
+	"transactions.reason",
+	"transactions.batch_id",
+	"transactions.message",
+	"transactions.category",
+	"transactions.reversal_of",
+	"transactions.reversed_at",
+	"transactions.purchase_id",
+	"transactions.kind",
+	"merch.stock",
+	"merch.max_per_user",
+	"merch.description",
+	"merch.category",
+	"merch.image_url",
+	"purchases.buyer_id",
+	"purchases.variant_id",
+	"purchases.status",
+	"purchases.status_updated_at",
+	"purchases.reversed_at",
+}
+
+// CheckSchema reports tables and columns of the current schema that are missing in the database
+func (r *PostgresRepository) CheckSchema(ctx context.Context) error {
+	const op = "postgres.CheckSchema"
+	const tablesQuery = `SELECT t FROM unnest($1::text[]) t WHERE to_regclass(t) IS NULL`
+	const columnsQuery = `SELECT c FROM unnest($1::text[]) c
+					WHERE NOT EXISTS (
+						SELECT 1 FROM information_schema.columns ic
+						WHERE ic.table_schema = current_schema()
+						AND ic.table_name || '.' || ic.column_name = c
+					)`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	missing, err := r.missingObjects(ctx, tablesQuery, schemaTables)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: migrations are not applied, missing tables: %s", op, strings.Join(missing, ", "))
+	}
+
+	missing, err = r.missingObjects(ctx, columnsQuery, schemaColumns)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: migrations are not applied, missing columns: %s", op, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// missingObjects runs query over names and returns the names it selects
+func (r *PostgresRepository) missingObjects(ctx context.Context, query string, names []string) ([]string, error) {
+	rows, err := r.pool.Query(ctx, query, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		missing = append(missing, name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
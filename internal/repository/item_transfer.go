@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+)
+
+// SetPurchaseOwner moves the purchased item to the inventory of another user
+func (r *PostgresRepository) SetPurchaseOwner(ctx context.Context, id int64, userId string) error {
+	const op = "postgres.SetPurchaseOwner"
+	const query = `UPDATE purchases SET user_id = $2 WHERE id = $1;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, id, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+func (r *PostgresRepository) LogItemTransfer(ctx context.Context, purchaseId int64, fromUserId, toUserId string) error {
+	const op = "postgres.LogItemTransfer"
+	const query = `INSERT INTO item_transfers(purchase_id, from_user_id, to_user_id)
+					VALUES ($1, $2, $3);`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, purchaseId, fromUserId, toUserId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
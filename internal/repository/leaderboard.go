@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"time"
+)
+
+// Transfers between colleagues only: operations of the system account (grants, allowances, refunds,
+// expired coins) and reversed transfers with their reversals are not counted.
+// created_at columns are TIMESTAMP in UTC, so since is converted to UTC explicitly
+// instead of relying on the session time zone.
+const leaderboardTransfersFilter = `t.from_user_id <> $1 AND t.to_user_id <> $1
+					AND t.reversed_at IS NULL AND t.reversal_of IS NULL
+					AND ($2::timestamptz IS NULL OR t.created_at >= ($2::timestamptz AT TIME ZONE 'UTC'))`
+
+var leaderboardQueries = map[string]string{
+	model.LeaderboardReceived: `SELECT t.to_user_id AS user_id, SUM(t.amount) AS value
+					FROM transactions t
+					WHERE ` + leaderboardTransfersFilter + `
+					GROUP BY t.to_user_id`,
+	model.LeaderboardSent: `SELECT t.from_user_id AS user_id, SUM(t.amount) AS value
+					FROM transactions t
+					WHERE ` + leaderboardTransfersFilter + `
+					GROUP BY t.from_user_id`,
+	model.LeaderboardThanked: `SELECT t.from_user_id AS user_id, COUNT(DISTINCT t.to_user_id) AS value
+					FROM transactions t
+					WHERE ` + leaderboardTransfersFilter + `
+					GROUP BY t.from_user_id`,
+	model.LeaderboardSpent: `SELECT p.buyer_id AS user_id, SUM(p.price) AS value
+					FROM purchases p
+					WHERE p.buyer_id <> $1 AND p.reversed_at IS NULL
+					AND ($2::timestamptz IS NULL OR p.created_at >= ($2::timestamptz AT TIME ZONE 'UTC'))
+					GROUP BY p.buyer_id`,
+}
+
+// GetLeaderboard returns top users by the metric since the given time, users with equal values share the rank
+func (r *PostgresRepository) GetLeaderboard(ctx context.Context, metric string, since *time.Time, limit int) ([]*model.LeaderboardEntry, error) {
+	const op = "postgres.GetLeaderboard"
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	aggregate, ok := leaderboardQueries[metric]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown metric %q", op, metric)
+	}
+	query := `SELECT RANK() OVER (ORDER BY s.value DESC), u.login, s.value
+					FROM (` + aggregate + `) s
+					JOIN users u ON u.id = s.user_id
+					ORDER BY s.value DESC, u.login
+					LIMIT $3`
+
+	rows, err := r.conn(ctx).Query(ctx, query, model.SystemUserId, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var entries []*model.LeaderboardEntry
+	for rows.Next() {
+		var e model.LeaderboardEntry
+		if err = rows.Scan(&e.Rank, &e.User, &e.Value); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		entries = append(entries, &e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return entries, nil
+}
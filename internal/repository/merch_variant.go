@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"github.com/jackc/pgx/v5"
+)
+
+// hasVariantsQuery checks whether merch m is sold by variants
+const hasVariantsQuery = `EXISTS (SELECT 1 FROM merch_variants mv WHERE mv.merch_id = m.id)`
+
+func (r *PostgresRepository) SetMerchDetails(ctx context.Context, merchId, description, category, imageURL string) error {
+	const op = "postgres.SetMerchDetails"
+	const query = `UPDATE merch
+					SET description = $2, category = $3, image_url = $4
+					WHERE id = $1;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, merchId, description, category, imageURL)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+func (r *PostgresRepository) CreateMerchVariant(ctx context.Context, variant *model.MerchVariant) (*model.MerchVariant, error) {
+	const op = "postgres.CreateMerchVariant"
+	const query = `INSERT INTO merch_variants(merch_id, size, color, stock)
+					VALUES ($1, $2, $3, $4)
+					RETURNING id, created_at;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	row := r.conn(ctx).QueryRow(ctx, query, variant.MerchId, variant.Size, variant.Color, variant.Stock)
+	if err := row.Scan(&variant.Id, &variant.CreatedAt); err != nil {
+		if r.isUniqueViolation(err) {
+			return nil, cstErrors.VariantAlreadyExistsError
+		}
+		if r.isCheckConstraintViolation(err) {
+			return nil, cstErrors.BadRequestDataError
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return variant, nil
+}
+
+func (r *PostgresRepository) GetMerchVariant(ctx context.Context, id string) (*model.MerchVariant, error) {
+	const op = "postgres.GetMerchVariant"
+	const query = `SELECT merch_id, size, color, stock, created_at
+					FROM merch_variants WHERE id = $1`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	v := model.MerchVariant{Id: id}
+	row := r.conn(ctx).QueryRow(ctx, query, id)
+	if err := row.Scan(&v.MerchId, &v.Size, &v.Color, &v.Stock, &v.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, cstErrors.NotFoundError
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &v, nil
+}
+
+// GetMerchVariants returns variants of the given merch ordered by merch, size and color
+func (r *PostgresRepository) GetMerchVariants(ctx context.Context, merchIds []string) ([]*model.MerchVariant, error) {
+	const op = "postgres.GetMerchVariants"
+	const query = `SELECT id, merch_id, size, color, stock, created_at
+					FROM merch_variants
+					WHERE merch_id = ANY($1::uuid[])
+					ORDER BY merch_id, size, color`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	rows, err := r.conn(ctx).Query(ctx, query, merchIds)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var variants []*model.MerchVariant
+	for rows.Next() {
+		var v model.MerchVariant
+		if err = rows.Scan(&v.Id, &v.MerchId, &v.Size, &v.Color, &v.Stock, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		variants = append(variants, &v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return variants, nil
+}
+
+func (r *PostgresRepository) SetVariantStock(ctx context.Context, id string, stock *int) error {
+	const op = "postgres.SetVariantStock"
+	const query = `UPDATE merch_variants
+					SET stock = $2
+					WHERE id = $1;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, id, stock)
+	if err != nil {
+		if r.isCheckConstraintViolation(err) {
+			return cstErrors.BadRequestDataError
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DecrementVariantStock(ctx context.Context, id string, quantity int) error {
+	const op = "postgres.DecrementVariantStock"
+	const query = `UPDATE merch_variants
+					SET stock = stock - $2
+					WHERE id = $1 AND stock IS NOT NULL;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, id, quantity); err != nil {
+		if r.isCheckConstraintViolation(err) {
+			return cstErrors.OutOfStockError
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) IncrementVariantStock(ctx context.Context, id string, quantity int) error {
+	const op = "postgres.IncrementVariantStock"
+	const query = `UPDATE merch_variants
+					SET stock = stock + $2
+					WHERE id = $1 AND stock IS NOT NULL;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, id, quantity); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -3,57 +3,143 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/config"
 	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
-	"github.com/lib/pq"
-	_ "github.com/lib/pq"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"net/url"
+	"time"
 )
 
 type PostgresRepository struct {
-	db *sql.DB
+	pool *pgxpool.Pool
 }
 
+const pingTimeout = 5 * time.Second
+
 func NewPostgresRepository(config config.DatabaseConfig) (*PostgresRepository, error) {
-	conn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s",
-		config.User, config.Password, config.Address, config.Name, config.SSLMode)
-	db, err := sql.Open("postgres", conn)
+	const op = "postgres.NewPostgresRepository"
+
+	poolConfig, err := pgxpool.ParseConfig(dsn(config))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	if err = db.Ping(); err != nil {
-		return nil, err
+	if config.MaxConns > 0 {
+		poolConfig.MaxConns = int32(config.MaxConns)
 	}
-	return &PostgresRepository{db: db}, nil
+	poolConfig.MinConns = int32(config.MinConns)
+	poolConfig.MaxConnLifetime = config.ConnMaxLifetime
+	poolConfig.MaxConnIdleTime = config.ConnMaxIdleTime
+	// TIMESTAMP columns are filled by now() in the session time zone, keep them in UTC
+	poolConfig.ConnConfig.RuntimeParams["timezone"] = "UTC"
+	// Queries are prepared on first use and cached per connection, the cache evicts
+	// least recently used statements and deallocates them on the server
+	if config.StatementCacheCapacity > 0 {
+		poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+		poolConfig.ConnConfig.StatementCacheCapacity = config.StatementCacheCapacity
+	} else {
+		poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err = waitForDB(pool, config); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &PostgresRepository{pool: pool}, nil
 }
 
-func (r *PostgresRepository) isCheckConstraintViolation(err error) bool {
-	if pgErr, ok := err.(*pq.Error); ok {
-		return pgErr.Code == "23514"
+// dsn builds a connection URL, credentials and database name are escaped
+func dsn(config config.DatabaseConfig) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.User, config.Password),
+		Host:     config.Address,
+		Path:     "/" + config.Name,
+		RawQuery: url.Values{"sslmode": {config.SSLMode}}.Encode(),
 	}
-	return false
+	return u.String()
+}
+
+// waitForDB pings the database until it answers, backing off exponentially between attempts
+func waitForDB(pool *pgxpool.Pool, config config.DatabaseConfig) error {
+	attempts := max(config.ConnectAttempts, 1)
+	backoff := config.ConnectBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err = pool.Ping(ctx)
+		cancel()
+		if err == nil || attempt >= attempts {
+			break
+		}
+		slog.Warn("database is not ready, retrying",
+			slog.String("address", config.Address),
+			slog.Int("attempt", attempt),
+			slog.Duration("retry_in", backoff),
+			slog.Any("error", err))
+		time.Sleep(backoff)
+		backoff = min(backoff*2, config.ConnectMaxBackoff)
+	}
+	if err != nil {
+		return fmt.Errorf("database %s unavailable after %d attempts: %w", config.Address, attempts, err)
+	}
+	return nil
+}
+
+// Close closes all connections of the pool
+func (r *PostgresRepository) Close() {
+	r.pool.Close()
+}
+
+// Stats returns connection pool statistics
+func (r *PostgresRepository) Stats() *pgxpool.Stat {
+	return r.pool.Stat()
+}
+
+func (r *PostgresRepository) isCheckConstraintViolation(err error) bool {
+	return hasErrorCode(err, pgerrcode.CheckViolation)
+}
+
+func (r *PostgresRepository) isUniqueViolation(err error) bool {
+	return hasErrorCode(err, pgerrcode.UniqueViolation)
+}
+
+func hasErrorCode(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
 }
 
 func (r *PostgresRepository) GetUserByLogin(ctx context.Context, login string) (*model.User, error) {
 	const op = "postgres.GetUserByLogin"
-	const query = `SELECT id, login, password, balance, created_at
+	const query = `SELECT id, login, password, balance, is_admin, created_at
 					FROM users WHERE login = $1`
 
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
 	var user model.User
 
-	row := r.db.QueryRowContext(ctx, query, login)
+	row := r.conn(ctx).QueryRow(ctx, query, login)
 
-	if err := row.Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, row.Err())
-	}
 	err := row.Scan(&user.Id,
 		&user.Username,
 		&user.Password,
 		&user.Balance,
+		&user.IsAdmin,
 		&user.CreatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, cstErrors.NotFoundError
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -63,19 +149,20 @@ func (r *PostgresRepository) GetUserByLogin(ctx context.Context, login string) (
 
 func (r *PostgresRepository) GetUserById(ctx context.Context, id string) (*model.User, error) {
 	const op = "postgres.GetUserById"
-	const query = `SELECT login, password, balance, created_at
+	const query = `SELECT login, password, balance, is_admin, created_at
 					FROM users WHERE id = $1`
 
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
 	var user model.User
-	row := r.db.QueryRowContext(ctx, query, id)
-	if err := row.Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
+	row := r.conn(ctx).QueryRow(ctx, query, id)
 	if err := row.Scan(&user.Username,
 		&user.Password,
 		&user.Balance,
+		&user.IsAdmin,
 		&user.CreatedAt); err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, cstErrors.NotFoundError
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -84,33 +171,114 @@ func (r *PostgresRepository) GetUserById(ctx context.Context, id string) (*model
 	return &user, nil
 }
 
+func (r *PostgresRepository) GetUserIdsByLogins(ctx context.Context, logins []string) (map[string]string, error) {
+	const op = "postgres.GetUserIdsByLogins"
+	const query = `SELECT login, id
+					FROM users WHERE login = ANY($1)`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	rows, err := r.conn(ctx).Query(ctx, query, logins)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]string, len(logins))
+	for rows.Next() {
+		var login, id string
+		if err = rows.Scan(&login, &id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids[login] = id
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ids, nil
+}
+
 func (r *PostgresRepository) CreateUser(ctx context.Context, user *model.User) (*model.User, error) {
 	const op = "postgres.CreateUser"
 	const query = `INSERT INTO users (login, password, balance)
 					VALUES ($1, $2, $3)
 					RETURNING id, created_at`
 
-	row := r.db.QueryRowContext(ctx, query, user.Username, user.Password, user.Balance)
-	if err := row.Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	row := r.conn(ctx).QueryRow(ctx, query, user.Username, user.Password, user.Balance)
 	if err := row.Scan(&user.Id,
 		&user.CreatedAt); err != nil {
+		if r.isUniqueViolation(err) {
+			return nil, cstErrors.UserAlreadyExistsError
+		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	return user, nil
 }
 
+// UpdateBalance changes the balance of the user, spent coins are taken from coin lots
+// that expire first (see consumeCoinLotsQuery). Both updates are sent in one batch,
+// outside a transaction the batch is atomic on its own.
 func (r *PostgresRepository) UpdateBalance(ctx context.Context, userId string, diffBalance int) error {
 	const op = "postgres.UpdateBalance"
 	const query = `UPDATE users
 					SET balance = balance + $1
 					WHERE id = $2;`
-	stmt, err := r.db.PrepareContext(ctx, query)
-	if err != nil {
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var batch pgx.Batch
+	batch.Queue(query, diffBalance, userId).Exec(func(res pgconn.CommandTag) error {
+		if res.RowsAffected() == 0 {
+			return cstErrors.NotFoundError
+		}
+		return nil
+	})
+	if diffBalance < 0 {
+		batch.Queue(consumeCoinLotsQuery, userId, -diffBalance)
+	}
+	if err := r.sendBatch(ctx, &batch); err != nil {
+		if errors.Is(err, cstErrors.NotFoundError) {
+			return err
+		}
+		if r.isCheckConstraintViolation(err) {
+			return cstErrors.NoCoinError
+		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	if _, err = stmt.ExecContext(ctx, diffBalance, userId); err != nil {
+	return nil
+}
+
+// TransferCoins moves coins from one user to another. Coins taken from the sender's lots reach
+// the recipient as lots with the same expiry (see moveCoinLotsQuery). All updates are sent in one batch.
+func (r *PostgresRepository) TransferCoins(ctx context.Context, fromUserId, toUserId string, amount int) error {
+	const op = "postgres.TransferCoins"
+	const query = `UPDATE users
+					SET balance = balance + $1
+					WHERE id = $2;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	checkFound := func(res pgconn.CommandTag) error {
+		if res.RowsAffected() == 0 {
+			return cstErrors.NotFoundError
+		}
+		return nil
+	}
+	var batch pgx.Batch
+	batch.Queue(query, -amount, fromUserId).Exec(checkFound)
+	batch.Queue(moveCoinLotsQuery, fromUserId, amount, toUserId)
+	batch.Queue(query, amount, toUserId).Exec(checkFound)
+	if err := r.sendBatch(ctx, &batch); err != nil {
+		if errors.Is(err, cstErrors.NotFoundError) {
+			return err
+		}
 		if r.isCheckConstraintViolation(err) {
 			return cstErrors.NoCoinError
 		}
@@ -119,70 +287,340 @@ func (r *PostgresRepository) UpdateBalance(ctx context.Context, userId string, d
 	return nil
 }
 
-func (r *PostgresRepository) LogTransferCoin(ctx context.Context, fromUserId, toUserId string, amount int) error {
-	const op = "postgres.TransferCoin"
-	const query = `INSERT INTO transactions(from_user_id, to_user_id, amount)
-					VALUES ($1, $2, $3);`
+// LockUsers locks rows of the users ordered by id until the transaction ends. Transactions that update
+// balances of several users lock them first, so they always take the locks in the same order.
+func (r *PostgresRepository) LockUsers(ctx context.Context, userIds []string) error {
+	const op = "postgres.LockUsers"
+	const query = `SELECT id FROM users
+					WHERE id = ANY($1::uuid[])
+					ORDER BY id
+					FOR UPDATE`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, userIds); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+func (r *PostgresRepository) SetUserAdmin(ctx context.Context, userId string, isAdmin bool) error {
+	const op = "postgres.SetUserAdmin"
+	const query = `UPDATE users
+					SET is_admin = $1
+					WHERE id = $2;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, isAdmin, userId)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	if _, err = stmt.ExecContext(ctx, fromUserId, toUserId, amount); err != nil {
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+func (r *PostgresRepository) LogTransaction(ctx context.Context, transaction *model.Transaction) error {
+	const op = "postgres.LogTransaction"
+	const query = `INSERT INTO transactions(from_user_id, to_user_id, amount, reason, batch_id, message, category,
+					reversal_of, purchase_id)
+					VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, '')::uuid, NULLIF($6, ''), NULLIF($7, ''),
+					NULLIF($8, 0), NULLIF($9, 0))
+					RETURNING id, created_at;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	row := r.conn(ctx).QueryRow(ctx, query,
+		transaction.FromUserId,
+		transaction.ToUserId,
+		transaction.Amount,
+		transaction.Reason,
+		transaction.BatchId,
+		transaction.Message,
+		transaction.Category,
+		transaction.ReversalOf,
+		transaction.PurchaseId)
+	if err := row.Scan(&transaction.Id, &transaction.CreatedAt); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
-func (r *PostgresRepository) GetMerchById(ctx context.Context, itemId string) (*model.Merch, error) {
-	const op = "postgres.GetMerchById"
-	const query = `SELECT name, price, is_selling, created_at
-					FROM merch WHERE id = $1`
+func (r *PostgresRepository) GetTransactionById(ctx context.Context, id int64) (*model.Transaction, error) {
+	const op = "postgres.GetTransactionById"
+	const query = `SELECT from_user_id, to_user_id, amount, COALESCE(reason, ''),
+					COALESCE(batch_id::text, ''), COALESCE(message, ''), COALESCE(category, ''),
+					COALESCE(reversal_of, 0), COALESCE(purchase_id, 0), COALESCE(kind, ''), reversed_at, created_at
+					FROM transactions WHERE id = $1`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var (
+		t                model.Transaction
+		fromUser, toUser sql.NullString
+		reversedAt       sql.NullTime
+	)
+	row := r.conn(ctx).QueryRow(ctx, query, id)
+	if err := row.Scan(&fromUser,
+		&toUser,
+		&t.Amount,
+		&t.Reason,
+		&t.BatchId,
+		&t.Message,
+		&t.Category,
+		&t.ReversalOf,
+		&t.PurchaseId,
+		&t.Kind,
+		&reversedAt,
+		&t.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, cstErrors.NotFoundError
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	t.Id = id
+	t.FromUserId = fromUser.String
+	t.ToUserId = toUser.String
+	if reversedAt.Valid {
+		t.ReversedAt = &reversedAt.Time
+	}
+	return &t, nil
+}
 
-	var merch model.Merch
+func (r *PostgresRepository) MarkTransactionReversed(ctx context.Context, id int64) error {
+	const op = "postgres.MarkTransactionReversed"
+	const query = `UPDATE transactions
+					SET reversed_at = now()
+					WHERE id = $1 AND reversed_at IS NULL;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.AlreadyReversedError
+	}
+	return nil
+}
 
-	row := r.db.QueryRowContext(ctx, query, itemId)
-	if err := row.Err(); err != nil {
+// GetPurchaseById locks the purchase row, so within a transaction its status
+// cannot be changed concurrently until the transaction ends
+func (r *PostgresRepository) GetPurchaseById(ctx context.Context, id int64) (*model.Purchase, error) {
+	const op = "postgres.GetPurchaseById"
+	const query = `SELECT user_id, COALESCE(buyer_id, user_id), merch_id, COALESCE(variant_id::text, ''), price, status, reversed_at, created_at
+					FROM purchases WHERE id = $1
+					FOR UPDATE`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var (
+		p          model.Purchase
+		reversedAt sql.NullTime
+	)
+	row := r.conn(ctx).QueryRow(ctx, query, id)
+	if err := row.Scan(&p.UserId,
+		&p.BuyerId,
+		&p.MerchId,
+		&p.VariantId,
+		&p.Price,
+		&p.Status,
+		&reversedAt,
+		&p.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, cstErrors.NotFoundError
+		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	p.Id = id
+	if reversedAt.Valid {
+		p.ReversedAt = &reversedAt.Time
+	}
+	return &p, nil
+}
+
+func (r *PostgresRepository) MarkPurchaseReversed(ctx context.Context, id int64) error {
+	const op = "postgres.MarkPurchaseReversed"
+	const query = `UPDATE purchases
+					SET reversed_at = now(), status = 'cancelled', status_updated_at = now()
+					WHERE id = $1 AND reversed_at IS NULL;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.AlreadyReversedError
+	}
+	return nil
+}
+
+func (r *PostgresRepository) SetPurchaseStatus(ctx context.Context, id int64, status string) error {
+	const op = "postgres.SetPurchaseStatus"
+	const query = `UPDATE purchases
+					SET status = $2, status_updated_at = now()
+					WHERE id = $1;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, id, status)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+// GetUserPurchases returns items owned by the user, gifts the user bought for others and items the user
+// handed over to colleagues. The first owner of the item tells a gift from a transfer: it is the sender
+// of the first transfer or the current owner if the item was never transferred.
+func (r *PostgresRepository) GetUserPurchases(ctx context.Context, userId string) ([]*model.PurchaseInfo, error) {
+	const op = "postgres.GetUserPurchases"
+	const query = `SELECT p.id, m.name, COALESCE(v.size, ''), COALESCE(v.color, ''),
+					CASE WHEN p.user_id = $1 AND fo.id = $1 AND p.buyer_id <> $1 THEN COALESCE(b.login, '') ELSE '' END,
+					CASE WHEN p.buyer_id = $1 AND fo.id <> $1 THEN COALESCE(f.login, '') ELSE '' END,
+					CASE WHEN p.user_id = $1 THEN COALESCE(s.login, '') ELSE '' END,
+					CASE WHEN p.user_id <> $1 AND fo.id = $1 THEN o.login ELSE '' END,
+					p.price, p.status, p.status_updated_at, p.created_at
+					FROM purchases p
+					JOIN merch m ON m.id = p.merch_id
+					JOIN users o ON o.id = p.user_id
+					LEFT JOIN users b ON b.id = p.buyer_id
+					LEFT JOIN merch_variants v ON v.id = p.variant_id
+					CROSS JOIN LATERAL (
+						SELECT COALESCE((SELECT t.from_user_id FROM item_transfers t
+										WHERE t.purchase_id = p.id
+										ORDER BY t.id LIMIT 1), p.user_id) AS id
+					) fo
+					LEFT JOIN users f ON f.id = fo.id
+					LEFT JOIN LATERAL (
+						SELECT t.from_user_id FROM item_transfers t
+						WHERE t.purchase_id = p.id
+						ORDER BY t.id DESC LIMIT 1
+					) lt ON true
+					LEFT JOIN users s ON s.id = lt.from_user_id
+					WHERE p.user_id = $1 OR p.buyer_id = $1
+					ORDER BY p.created_at DESC, p.id DESC`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	rows, err := r.conn(ctx).Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var purchases []*model.PurchaseInfo
+	for rows.Next() {
+		var (
+			p       model.PurchaseInfo
+			variant model.MerchVariant
+		)
+		if err = rows.Scan(&p.Id, &p.Item, &variant.Size, &variant.Color, &p.GiftFrom, &p.GiftTo, &p.TransferredFrom, &p.TransferredTo,
+			&p.Price, &p.Status, &p.StatusUpdatedAt, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		p.Variant = variant.Label()
+		purchases = append(purchases, &p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return purchases, nil
+}
+
+// GetMerchById returns the merch together with the price schedule active right now, if any
+func (r *PostgresRepository) GetMerchById(ctx context.Context, itemId string) (*model.Merch, error) {
+	const op = "postgres.GetMerchById"
+	const query = `SELECT m.name, m.description, m.category, m.image_url, m.price, m.is_selling, m.stock,
+					m.max_per_user, ` + hasVariantsQuery + `, m.created_at,
+					s.id, s.starts_at, s.ends_at, s.sale_price, s.percent_off
+					FROM merch m
+					LEFT JOIN LATERAL (` + activePriceScheduleQuery + `) s ON true
+					WHERE m.id = $1`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var (
+		merch model.Merch
+		sale  nullPriceSchedule
+	)
+
+	row := r.conn(ctx).QueryRow(ctx, query, itemId)
 	if err := row.Scan(&merch.Name,
+		&merch.Description,
+		&merch.Category,
+		&merch.ImageURL,
 		&merch.Price,
 		&merch.IsSelling,
-		&merch.CreatedAt); err != nil {
-		if err == sql.ErrNoRows {
+		&merch.Stock,
+		&merch.MaxPerUser,
+		&merch.HasVariants,
+		&merch.CreatedAt,
+		&sale.id,
+		&sale.startsAt,
+		&sale.endsAt,
+		&sale.salePrice,
+		&sale.percentOff); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, cstErrors.NotFoundError
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	merch.Id = itemId
+	merch.Sale = sale.toModel(itemId)
 	return &merch, nil
 }
 
-func (r *PostgresRepository) LogBuyMerch(ctx context.Context, userId, merchId string, price int) error {
+// LogBuyMerch records the purchase paid by the buyer, the item goes to the owner's inventory
+func (r *PostgresRepository) LogBuyMerch(ctx context.Context, buyerId, ownerId, merchId, variantId string, price int) (int64, error) {
 	const op = "postgres.LogBuyMerch"
-	const query = `INSERT INTO purchases(user_id, merch_id, price)
-					VALUES ($1, $2, $3);`
+	const query = `INSERT INTO purchases(buyer_id, user_id, merch_id, variant_id, price)
+					VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5)
+					RETURNING id;`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-	if _, err = stmt.ExecContext(ctx, userId, merchId, price); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var purchaseId int64
+	if err := r.conn(ctx).QueryRow(ctx, query, buyerId, ownerId, merchId, variantId, price).Scan(&purchaseId); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
-	return nil
+	return purchaseId, nil
 }
 
-func (r *PostgresRepository) GetTransactionHistoryReceived(ctx context.Context, userId string) ([]*model.ReceivedCoin, error) {
+func (r *PostgresRepository) GetTransactionHistoryReceived(ctx context.Context, userId, category string) ([]*model.ReceivedCoin, error) {
 	const op = "postgres.GetTransactionHistoryReceived"
-	const query = `SELECT u.login from_user, amount
+	const query = `SELECT t.id, u.login from_user, amount, COALESCE(reason, ''),
+					COALESCE(message, ''), COALESCE(category, ''), reversed_at IS NOT NULL
 					FROM transactions t
 					LEFT JOIN users u on u.id = t.from_user_id
-					WHERE to_user_id = $1
+					WHERE to_user_id = $1 AND ($2 = '' OR category = $2)
 					ORDER BY t.created_at DESC`
 
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
 	var err error
-	rows, err := r.db.QueryContext(ctx, query, userId)
+	rows, err := r.conn(ctx).Query(ctx, query, userId, category)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -194,7 +632,7 @@ func (r *PostgresRepository) GetTransactionHistoryReceived(ctx context.Context,
 			t        model.ReceivedCoin
 			fromUser sql.NullString
 		)
-		if err = rows.Scan(&fromUser, &t.Amount); err != nil {
+		if err = rows.Scan(&t.Id, &fromUser, &t.Amount, &t.Reason, &t.Message, &t.Category, &t.Reversed); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 		if fromUser.Valid {
@@ -211,16 +649,20 @@ func (r *PostgresRepository) GetTransactionHistoryReceived(ctx context.Context,
 	return transactions, nil
 }
 
-func (r *PostgresRepository) GetTransactionHistorySent(ctx context.Context, userId string) ([]*model.SentCoin, error) {
-	const op = "postgres.GetTransactionHistoryReceived"
-	const query = `SELECT u.login to_user, amount
+func (r *PostgresRepository) GetTransactionHistorySent(ctx context.Context, userId, category string) ([]*model.SentCoin, error) {
+	const op = "postgres.GetTransactionHistorySent"
+	const query = `SELECT t.id, u.login to_user, amount, COALESCE(reason, ''),
+					COALESCE(message, ''), COALESCE(category, ''), reversed_at IS NOT NULL
 					FROM transactions t
 					LEFT JOIN users u on u.id = t.to_user_id
-					WHERE from_user_id = $1
+					WHERE from_user_id = $1 AND ($2 = '' OR category = $2)
 					ORDER BY t.created_at DESC`
 
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
 	var err error
-	rows, err := r.db.QueryContext(ctx, query, userId)
+	rows, err := r.conn(ctx).Query(ctx, query, userId, category)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -233,7 +675,7 @@ func (r *PostgresRepository) GetTransactionHistorySent(ctx context.Context, user
 			toUser sql.NullString
 		)
 
-		if err = rows.Scan(&toUser, &t.Amount); err != nil {
+		if err = rows.Scan(&t.Id, &toUser, &t.Amount, &t.Reason, &t.Message, &t.Category, &t.Reversed); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 		if toUser.Valid {
@@ -250,16 +692,23 @@ func (r *PostgresRepository) GetTransactionHistorySent(ctx context.Context, user
 	return transactions, nil
 }
 
+// GetInventory returns merch currently owned by the user (bought, gifted or transferred) grouped by item,
+// items bought by variant are broken down by variant
 func (r *PostgresRepository) GetInventory(ctx context.Context, userId string) ([]*model.InfoInventory, error) {
 	const op = "postgres.GetInventory"
-	const query = `SELECT m.name, COUNT(*)
+	const query = `SELECT m.name, v.id IS NOT NULL, COALESCE(v.size, ''), COALESCE(v.color, ''), COUNT(*)
 					FROM purchases p
 					LEFT JOIN merch m on p.merch_id = m.id
-					WHERE user_id = $1
-					GROUP BY m.name`
+					LEFT JOIN merch_variants v on p.variant_id = v.id
+					WHERE p.user_id = $1 AND p.reversed_at IS NULL
+					GROUP BY m.name, v.id, v.size, v.color
+					ORDER BY m.name, v.size, v.color`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
 
 	var err error
-	rows, err := r.db.QueryContext(ctx, query, userId)
+	rows, err := r.conn(ctx).Query(ctx, query, userId)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -267,11 +716,23 @@ func (r *PostgresRepository) GetInventory(ctx context.Context, userId string) ([
 
 	var inventory []*model.InfoInventory
 	for rows.Next() {
-		var i model.InfoInventory
-		if err = rows.Scan(&i.Type, &i.Quantity); err != nil {
+		var (
+			name       string
+			hasVariant bool
+			variant    model.MerchVariant
+			quantity   int
+		)
+		if err = rows.Scan(&name, &hasVariant, &variant.Size, &variant.Color, &quantity); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		inventory = append(inventory, &i)
+		if len(inventory) == 0 || inventory[len(inventory)-1].Type != name {
+			inventory = append(inventory, &model.InfoInventory{Type: name})
+		}
+		i := inventory[len(inventory)-1]
+		i.Quantity += quantity
+		if hasVariant {
+			i.Variants = append(i.Variants, &model.InventoryVariant{Variant: variant.Label(), Quantity: quantity})
+		}
 	}
 
 	if err = rows.Err(); err != nil {
@@ -282,17 +743,221 @@ func (r *PostgresRepository) GetInventory(ctx context.Context, userId string) ([
 
 func (r *PostgresRepository) CreateMerch(ctx context.Context, merch *model.Merch) (*model.Merch, error) {
 	const op = "postgres.CreateMerch"
-	const query = `INSERT INTO merch(name, price, is_selling)
-					VALUES ($1, $2, $3)
+	const query = `INSERT INTO merch(name, price, is_selling, stock, description, category, image_url)
+					VALUES ($1, $2, $3, $4, $5, $6, $7)
 					RETURNING id, created_at;`
 
-	row := r.db.QueryRowContext(ctx, query, merch.Name, merch.Price, merch.IsSelling)
-	if err := row.Err(); err != nil {
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	row := r.conn(ctx).QueryRow(ctx, query, merch.Name, merch.Price, merch.IsSelling, merch.Stock,
+		merch.Description, merch.Category, merch.ImageURL)
+	if err := row.Scan(&merch.Id,
+		&merch.CreatedAt); err != nil {
+		if r.isUniqueViolation(err) {
+			return nil, cstErrors.MerchAlreadyExistsError
+		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	return merch, nil
+}
+
+func (r *PostgresRepository) GetMerchByName(ctx context.Context, name string) (*model.Merch, error) {
+	const op = "postgres.GetMerchByName"
+	const query = `SELECT m.id, m.description, m.category, m.image_url, m.price, m.is_selling, m.stock,
+					m.max_per_user, ` + hasVariantsQuery + `, m.created_at
+					FROM merch m WHERE m.name = $1`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var merch model.Merch
+
+	row := r.conn(ctx).QueryRow(ctx, query, name)
 	if err := row.Scan(&merch.Id,
+		&merch.Description,
+		&merch.Category,
+		&merch.ImageURL,
+		&merch.Price,
+		&merch.IsSelling,
+		&merch.Stock,
+		&merch.MaxPerUser,
+		&merch.HasVariants,
 		&merch.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, cstErrors.NotFoundError
+		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return merch, nil
+	merch.Name = name
+	return &merch, nil
+}
+
+func (r *PostgresRepository) SetMerchSelling(ctx context.Context, merchId string, isSelling bool) error {
+	const op = "postgres.SetMerchSelling"
+	const query = `UPDATE merch
+					SET is_selling = $1
+					WHERE id = $2;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, isSelling, merchId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+// DecrementMerchStock takes quantity items from the stock, merch with unlimited stock is left untouched
+func (r *PostgresRepository) DecrementMerchStock(ctx context.Context, merchId string, quantity int) error {
+	const op = "postgres.DecrementMerchStock"
+	const query = `UPDATE merch
+					SET stock = stock - $2
+					WHERE id = $1 AND stock IS NOT NULL;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, merchId, quantity); err != nil {
+		if r.isCheckConstraintViolation(err) {
+			return cstErrors.OutOfStockError
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// IncrementMerchStock returns quantity items to the stock, merch with unlimited stock is left untouched
+func (r *PostgresRepository) IncrementMerchStock(ctx context.Context, merchId string, quantity int) error {
+	const op = "postgres.IncrementMerchStock"
+	const query = `UPDATE merch
+					SET stock = stock + $2
+					WHERE id = $1 AND stock IS NOT NULL;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, merchId, quantity); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) SetMerchStock(ctx context.Context, merchId string, stock *int) error {
+	const op = "postgres.SetMerchStock"
+	const query = `UPDATE merch
+					SET stock = $1
+					WHERE id = $2;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, stock, merchId)
+	if err != nil {
+		if r.isCheckConstraintViolation(err) {
+			return cstErrors.BadRequestDataError
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+// RestockMerch adds quantity to a tracked stock and returns the new stock
+func (r *PostgresRepository) RestockMerch(ctx context.Context, merchId string, quantity int) (int, error) {
+	const op = "postgres.RestockMerch"
+	const query = `UPDATE merch
+					SET stock = stock + $1
+					WHERE id = $2 AND stock IS NOT NULL
+					RETURNING stock;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var stock int
+	row := r.conn(ctx).QueryRow(ctx, query, quantity, merchId)
+	if err := row.Scan(&stock); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, cstErrors.StockNotTrackedError
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return stock, nil
+}
+
+func (r *PostgresRepository) GetLowStockMerch(ctx context.Context, threshold int) ([]*model.Merch, error) {
+	const op = "postgres.GetLowStockMerch"
+	const query = `SELECT id, name, price, is_selling, stock, max_per_user, created_at
+					FROM merch
+					WHERE stock IS NOT NULL AND stock <= $1
+					ORDER BY stock, name`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	rows, err := r.conn(ctx).Query(ctx, query, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var merchList []*model.Merch
+	for rows.Next() {
+		var m model.Merch
+		if err = rows.Scan(&m.Id, &m.Name, &m.Price, &m.IsSelling, &m.Stock, &m.MaxPerUser, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		merchList = append(merchList, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return merchList, nil
+}
+
+func (r *PostgresRepository) SetMerchMaxPerUser(ctx context.Context, merchId string, maxPerUser *int) error {
+	const op = "postgres.SetMerchMaxPerUser"
+	const query = `UPDATE merch
+					SET max_per_user = $1
+					WHERE id = $2;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, maxPerUser, merchId)
+	if err != nil {
+		if r.isCheckConstraintViolation(err) {
+			return cstErrors.BadRequestDataError
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+// CountUserPurchases counts items of merch bought by the user including gifts to others,
+// refunded purchases are not counted
+func (r *PostgresRepository) CountUserPurchases(ctx context.Context, userId, merchId string) (int, error) {
+	const op = "postgres.CountUserPurchases"
+	const query = `SELECT COUNT(*)
+					FROM purchases
+					WHERE buyer_id = $1 AND merch_id = $2 AND reversed_at IS NULL`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var count int
+	if err := r.conn(ctx).QueryRow(ctx, query, userId, merchId).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
 }
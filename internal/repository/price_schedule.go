@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+)
+
+// activePriceScheduleQuery selects the cheapest schedule of merch m active at the moment.
+// It is meant to be used in LEFT JOIN LATERAL.
+const activePriceScheduleQuery = `SELECT ps.id, ps.starts_at, ps.ends_at, ps.sale_price, ps.percent_off
+					FROM price_schedules ps
+					WHERE ps.merch_id = m.id AND ps.starts_at <= now() AND ps.ends_at > now()
+					ORDER BY COALESCE(ps.sale_price, m.price * (100 - ps.percent_off) / 100), ps.starts_at DESC
+					LIMIT 1`
+
+type nullPriceSchedule struct {
+	id         sql.NullInt64
+	startsAt   sql.NullTime
+	endsAt     sql.NullTime
+	salePrice  *int
+	percentOff *int
+}
+
+func (s *nullPriceSchedule) toModel(merchId string) *model.PriceSchedule {
+	if !s.id.Valid {
+		return nil
+	}
+	return &model.PriceSchedule{
+		Id:         s.id.Int64,
+		MerchId:    merchId,
+		StartsAt:   s.startsAt.Time,
+		EndsAt:     s.endsAt.Time,
+		SalePrice:  s.salePrice,
+		PercentOff: s.percentOff,
+	}
+}
+
+func (r *PostgresRepository) CreatePriceSchedule(ctx context.Context, schedule *model.PriceSchedule) (*model.PriceSchedule, error) {
+	const op = "postgres.CreatePriceSchedule"
+	const query = `INSERT INTO price_schedules(merch_id, starts_at, ends_at, sale_price, percent_off)
+					VALUES ($1, $2, $3, $4, $5)
+					RETURNING id, created_at;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	row := r.conn(ctx).QueryRow(ctx, query,
+		schedule.MerchId,
+		schedule.StartsAt,
+		schedule.EndsAt,
+		schedule.SalePrice,
+		schedule.PercentOff)
+	if err := row.Scan(&schedule.Id, &schedule.CreatedAt); err != nil {
+		if r.isCheckConstraintViolation(err) {
+			return nil, cstErrors.BadRequestDataError
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return schedule, nil
+}
+
+func (r *PostgresRepository) GetPriceSchedules(ctx context.Context, merchId string) ([]*model.PriceSchedule, error) {
+	const op = "postgres.GetPriceSchedules"
+	const query = `SELECT id, starts_at, ends_at, sale_price, percent_off, created_at
+					FROM price_schedules
+					WHERE merch_id = $1
+					ORDER BY starts_at`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	rows, err := r.conn(ctx).Query(ctx, query, merchId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var schedules []*model.PriceSchedule
+	for rows.Next() {
+		s := model.PriceSchedule{MerchId: merchId}
+		if err = rows.Scan(&s.Id, &s.StartsAt, &s.EndsAt, &s.SalePrice, &s.PercentOff, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		schedules = append(schedules, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return schedules, nil
+}
+
+func (r *PostgresRepository) DeletePriceSchedule(ctx context.Context, id int64) error {
+	const op = "postgres.DeletePriceSchedule"
+	const query = `DELETE FROM price_schedules WHERE id = $1;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+// GetCatalog returns merch on sale with active price schedules resolved, empty category means any
+func (r *PostgresRepository) GetCatalog(ctx context.Context, category string) ([]*model.Merch, error) {
+	const op = "postgres.GetCatalog"
+	const query = `SELECT m.id, m.name, m.description, m.category, m.image_url, m.price, m.is_selling, m.stock,
+					m.max_per_user, ` + hasVariantsQuery + `, m.created_at,
+					s.id, s.starts_at, s.ends_at, s.sale_price, s.percent_off
+					FROM merch m
+					LEFT JOIN LATERAL (` + activePriceScheduleQuery + `) s ON true
+					WHERE m.is_selling AND ($1 = '' OR m.category = $1)
+					ORDER BY m.name`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	rows, err := r.conn(ctx).Query(ctx, query, category)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var catalog []*model.Merch
+	for rows.Next() {
+		var (
+			m    model.Merch
+			sale nullPriceSchedule
+		)
+		if err = rows.Scan(&m.Id, &m.Name, &m.Description, &m.Category, &m.ImageURL, &m.Price, &m.IsSelling, &m.Stock,
+			&m.MaxPerUser, &m.HasVariants, &m.CreatedAt, &sale.id, &sale.startsAt, &sale.endsAt, &sale.salePrice, &sale.percentOff); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		m.Sale = sale.toModel(m.Id)
+		catalog = append(catalog, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return catalog, nil
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"github.com/jackc/pgx/v5"
+)
+
+const promoCodeColumns = `p.id, p.code, p.discount_type, p.discount_value, p.max_uses, p.max_uses_per_user,
+					p.uses, p.expires_at, p.created_at,
+					ARRAY(SELECT pm.merch_id::text FROM promo_code_merch pm WHERE pm.promo_code_id = p.id)`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPromoCode(row rowScanner) (*model.PromoCode, error) {
+	var (
+		promo     model.PromoCode
+		expiresAt sql.NullTime
+		merchIds  []string
+	)
+	if err := row.Scan(&promo.Id,
+		&promo.Code,
+		&promo.DiscountType,
+		&promo.DiscountValue,
+		&promo.MaxUses,
+		&promo.MaxUsesPerUser,
+		&promo.Uses,
+		&expiresAt,
+		&promo.CreatedAt,
+		&merchIds); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		promo.ExpiresAt = &expiresAt.Time
+	}
+	promo.MerchIds = merchIds
+	return &promo, nil
+}
+
+// CreatePromoCode stores the code together with the list of merch it is restricted to
+func (r *PostgresRepository) CreatePromoCode(ctx context.Context, promo *model.PromoCode) (*model.PromoCode, error) {
+	const op = "postgres.CreatePromoCode"
+	const query = `INSERT INTO promo_codes(code, discount_type, discount_value, max_uses, max_uses_per_user, expires_at)
+					VALUES ($1, $2, $3, $4, $5, $6)
+					RETURNING id, created_at;`
+	const merchQuery = `INSERT INTO promo_code_merch(promo_code_id, merch_id)
+					SELECT $1, unnest($2::uuid[]);`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	err := r.WithinTransaction(ctx, func(ctx context.Context) error {
+		row := r.conn(ctx).QueryRow(ctx, query,
+			promo.Code,
+			promo.DiscountType,
+			promo.DiscountValue,
+			promo.MaxUses,
+			promo.MaxUsesPerUser,
+			promo.ExpiresAt)
+		if err := row.Scan(&promo.Id, &promo.CreatedAt); err != nil {
+			if r.isUniqueViolation(err) {
+				return cstErrors.PromoAlreadyExistsError
+			}
+			if r.isCheckConstraintViolation(err) {
+				return cstErrors.BadRequestDataError
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if len(promo.MerchIds) == 0 {
+			return nil
+		}
+		if _, err := r.conn(ctx).Exec(ctx, merchQuery, promo.Id, promo.MerchIds); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+func (r *PostgresRepository) GetPromoCodeByCode(ctx context.Context, code string) (*model.PromoCode, error) {
+	const op = "postgres.GetPromoCodeByCode"
+	const query = `SELECT ` + promoCodeColumns + `
+					FROM promo_codes p WHERE p.code = $1`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	promo, err := scanPromoCode(r.conn(ctx).QueryRow(ctx, query, code))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, cstErrors.NotFoundError
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return promo, nil
+}
+
+func (r *PostgresRepository) GetPromoCodes(ctx context.Context) ([]*model.PromoCode, error) {
+	const op = "postgres.GetPromoCodes"
+	const query = `SELECT ` + promoCodeColumns + `
+					FROM promo_codes p
+					ORDER BY p.created_at DESC`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	rows, err := r.conn(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var promos []*model.PromoCode
+	for rows.Next() {
+		promo, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		promos = append(promos, promo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return promos, nil
+}
+
+// RedeemPromoCode takes one use of the code. The update locks the code row until the
+// transaction ends, so concurrent redemptions of the same code are serialized.
+func (r *PostgresRepository) RedeemPromoCode(ctx context.Context, promoId int64) error {
+	const op = "postgres.RedeemPromoCode"
+	const query = `UPDATE promo_codes SET uses = uses + 1
+					WHERE id = $1
+					AND (max_uses IS NULL OR uses < max_uses)
+					AND (expires_at IS NULL OR expires_at > now());`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, promoId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.PromoLimitError
+	}
+	return nil
+}
+
+func (r *PostgresRepository) CountPromoRedemptions(ctx context.Context, promoId int64, userId string) (int, error) {
+	const op = "postgres.CountPromoRedemptions"
+	const query = `SELECT COUNT(*)
+					FROM promo_redemptions
+					WHERE promo_code_id = $1 AND user_id = $2`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	var count int
+	if err := r.conn(ctx).QueryRow(ctx, query, promoId, userId).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
+}
+
+func (r *PostgresRepository) LogPromoRedemption(ctx context.Context, promoId int64, userId string, purchaseId int64, discount int) error {
+	const op = "postgres.LogPromoRedemption"
+	const query = `INSERT INTO promo_redemptions(promo_code_id, user_id, purchase_id, discount)
+					VALUES ($1, $2, $3, $4);`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, promoId, userId, purchaseId, discount); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ReleasePromoRedemption gives back the use of the promo code taken by the purchase, if any,
+// so refunded orders do not count against the code limits
+func (r *PostgresRepository) ReleasePromoRedemption(ctx context.Context, purchaseId int64) error {
+	const op = "postgres.ReleasePromoRedemption"
+	const query = `WITH released AS (
+						DELETE FROM promo_redemptions
+						WHERE purchase_id = $1
+						RETURNING promo_code_id
+					)
+					UPDATE promo_codes p SET uses = p.uses - 1
+					FROM released r WHERE p.id = r.promo_code_id;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, purchaseId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
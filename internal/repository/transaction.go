@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// querier is implemented by both *pgxpool.Pool and pgx.Tx
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+type txCtxKey struct{}
+
+// conn returns the transaction bound to ctx by WithinTransaction or the pool otherwise
+func (r *PostgresRepository) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txCtxKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return r.pool
+}
+
+// WithinTransaction runs fn in a database transaction. Nested calls join the outer transaction.
+func (r *PostgresRepository) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	const op = "postgres.WithinTransaction"
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, ok := ctx.Value(txCtxKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = fn(context.WithValue(ctx, txCtxKey{}, tx)); err != nil {
+		// The context may be already cancelled, the rollback must still reach the server
+		_ = tx.Rollback(context.WithoutCancel(ctx))
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// sendBatch sends all queued queries in one round trip, callbacks of the queued queries run
+// in order and the first error is returned. Outside a transaction the batch is executed
+// as an implicit transaction.
+func (r *PostgresRepository) sendBatch(ctx context.Context, batch *pgx.Batch) error {
+	return r.conn(ctx).SendBatch(ctx, batch).Close()
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+)
+
+// AddToWishlist saves the merch for the user, adding it twice is a no-op
+func (r *PostgresRepository) AddToWishlist(ctx context.Context, userId, merchId string) error {
+	const op = "postgres.AddToWishlist"
+	const query = `INSERT INTO wishlist_items(user_id, merch_id)
+					VALUES ($1, $2)
+					ON CONFLICT DO NOTHING;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	if _, err := r.conn(ctx).Exec(ctx, query, userId, merchId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RemoveFromWishlist(ctx context.Context, userId, merchId string) error {
+	const op = "postgres.RemoveFromWishlist"
+	const query = `DELETE FROM wishlist_items WHERE user_id = $1 AND merch_id = $2;`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	res, err := r.conn(ctx).Exec(ctx, query, userId, merchId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return cstErrors.NotFoundError
+	}
+	return nil
+}
+
+// GetWishlist returns saved merch with its active price schedule, latest first
+func (r *PostgresRepository) GetWishlist(ctx context.Context, userId string) ([]*model.WishlistEntry, error) {
+	const op = "postgres.GetWishlist"
+	const query = `SELECT w.created_at, m.id, m.name, m.price, m.is_selling, m.stock, m.created_at,
+					s.id, s.starts_at, s.ends_at, s.sale_price, s.percent_off
+					FROM wishlist_items w
+					JOIN merch m ON m.id = w.merch_id
+					LEFT JOIN LATERAL (` + activePriceScheduleQuery + `) s ON true
+					WHERE w.user_id = $1
+					ORDER BY w.created_at DESC`
+
+	ctx, span := tracing.StartQuery(ctx, op)
+	defer span.End()
+
+	rows, err := r.conn(ctx).Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var wishlist []*model.WishlistEntry
+	for rows.Next() {
+		var (
+			entry = model.WishlistEntry{Merch: &model.Merch{}}
+			m     = entry.Merch
+			sale  nullPriceSchedule
+		)
+		if err = rows.Scan(&entry.AddedAt, &m.Id, &m.Name, &m.Price, &m.IsSelling, &m.Stock, &m.CreatedAt,
+			&sale.id, &sale.startsAt, &sale.endsAt, &sale.salePrice, &sale.percentOff); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		m.Sale = sale.toModel(m.Id)
+		wishlist = append(wishlist, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return wishlist, nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+)
+
+type CartRepository interface {
+	GetMerchById(ctx context.Context, itemId string) (*model.Merch, error)
+	GetMerchVariant(ctx context.Context, id string) (*model.MerchVariant, error)
+
+	AddToCart(ctx context.Context, userId, merchId, variantId string, quantity int) error
+	RemoveFromCart(ctx context.Context, userId, merchId, variantId string) error
+	GetCartItems(ctx context.Context, userId string) ([]*model.CartItem, error)
+}
+
+type CartService struct {
+	repo CartRepository
+}
+
+func NewCartService(repo CartRepository) *CartService {
+	return &CartService{repo: repo}
+}
+
+func (c *CartService) AddItem(ctx context.Context, userId, itemId, variantId string, quantity int) error {
+	const op = "CartService.AddItem"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if quantity <= 0 {
+		return cstErrors.BadRequestDataError
+	}
+
+	merch, err := c.repo.GetMerchById(ctx, itemId)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !merch.IsSelling {
+		return cstErrors.NoSellingMerchError
+	}
+	if _, err = resolveVariant(ctx, c.repo, merch, variantId); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = c.repo.AddToCart(ctx, userId, itemId, variantId, quantity); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (c *CartService) RemoveItem(ctx context.Context, userId, itemId, variantId string) error {
+	const op = "CartService.RemoveItem"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := c.repo.RemoveFromCart(ctx, userId, itemId, variantId); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (c *CartService) GetCart(ctx context.Context, userId string) (*model.CartResponse, error) {
+	const op = "CartService.GetCart"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	items, err := c.repo.GetCartItems(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return buildCart(items), nil
+}
+
+// buildCart prices cart lines at the current effective prices
+func buildCart(items []*model.CartItem) *model.CartResponse {
+	cart := &model.CartResponse{Items: make([]*model.CartLine, 0, len(items))}
+	for _, item := range items {
+		price := item.Merch.EffectivePrice()
+		line := &model.CartLine{
+			Item:      item.Merch.Id,
+			Name:      item.Merch.Name,
+			Quantity:  item.Quantity,
+			Price:     price,
+			LineTotal: price * item.Quantity,
+		}
+		if item.Variant != nil {
+			line.Variant = item.Variant.Label()
+			line.VariantId = item.Variant.Id
+		}
+		cart.Items = append(cart.Items, line)
+		cart.Total += line.LineTotal
+	}
+	return cart
+}
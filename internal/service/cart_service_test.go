@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+)
+
+type MockCartRepository struct {
+	mock.Mock
+}
+
+func (m *MockCartRepository) GetMerchById(ctx context.Context, itemId string) (*model.Merch, error) {
+	args := m.Called(ctx, itemId)
+	if merch := args.Get(0); merch != nil {
+		return merch.(*model.Merch), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartRepository) GetMerchVariant(ctx context.Context, id string) (*model.MerchVariant, error) {
+	args := m.Called(ctx, id)
+	if variant := args.Get(0); variant != nil {
+		return variant.(*model.MerchVariant), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartRepository) AddToCart(ctx context.Context, userId, merchId, variantId string, quantity int) error {
+	args := m.Called(ctx, userId, merchId, variantId, quantity)
+	return args.Error(0)
+}
+
+func (m *MockCartRepository) RemoveFromCart(ctx context.Context, userId, merchId, variantId string) error {
+	args := m.Called(ctx, userId, merchId, variantId)
+	return args.Error(0)
+}
+
+func (m *MockCartRepository) GetCartItems(ctx context.Context, userId string) ([]*model.CartItem, error) {
+	args := m.Called(ctx, userId)
+	if items := args.Get(0); items != nil {
+		return items.([]*model.CartItem), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// --- Tests for CartService.AddItem ---
+
+func TestCartService_AddItem_BadQuantity(t *testing.T) {
+	repo := new(MockCartRepository)
+	svc := NewCartService(repo)
+	ctx := context.Background()
+
+	err := svc.AddItem(ctx, "user1", "item1", "", 0)
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	repo.AssertNotCalled(t, "AddToCart", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCartService_AddItem_NotFound(t *testing.T) {
+	repo := new(MockCartRepository)
+	svc := NewCartService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchById", ctx, "ghost").Return(nil, cstErrors.NotFoundError)
+
+	err := svc.AddItem(ctx, "user1", "ghost", "", 1)
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	repo.AssertExpectations(t)
+}
+
+func TestCartService_AddItem_NotSelling(t *testing.T) {
+	repo := new(MockCartRepository)
+	svc := NewCartService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchById", ctx, "item1").Return(&model.Merch{Id: "item1", IsSelling: false}, nil)
+
+	err := svc.AddItem(ctx, "user1", "item1", "", 1)
+	assert.Equal(t, cstErrors.NoSellingMerchError, err)
+	repo.AssertExpectations(t)
+}
+
+func TestCartService_AddItem_Success(t *testing.T) {
+	repo := new(MockCartRepository)
+	svc := NewCartService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchById", ctx, "item1").Return(&model.Merch{Id: "item1", IsSelling: true}, nil)
+	repo.On("AddToCart", ctx, "user1", "item1", "", 3).Return(nil)
+
+	err := svc.AddItem(ctx, "user1", "item1", "", 3)
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestCartService_AddItem_VariantRequired(t *testing.T) {
+	repo := new(MockCartRepository)
+	svc := NewCartService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchById", ctx, "item1").Return(&model.Merch{Id: "item1", IsSelling: true, HasVariants: true}, nil)
+
+	err := svc.AddItem(ctx, "user1", "item1", "", 1)
+	assert.Equal(t, cstErrors.VariantRequiredError, err)
+	repo.AssertExpectations(t)
+}
+
+func TestCartService_AddItem_Variant(t *testing.T) {
+	repo := new(MockCartRepository)
+	svc := NewCartService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchById", ctx, "item1").Return(&model.Merch{Id: "item1", IsSelling: true, HasVariants: true}, nil)
+	repo.On("GetMerchVariant", ctx, "var1").Return(&model.MerchVariant{Id: "var1", MerchId: "item1", Size: "M"}, nil)
+	repo.On("AddToCart", ctx, "user1", "item1", "var1", 1).Return(nil)
+
+	err := svc.AddItem(ctx, "user1", "item1", "var1", 1)
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for CartService.RemoveItem ---
+
+func TestCartService_RemoveItem_NotInCart(t *testing.T) {
+	repo := new(MockCartRepository)
+	svc := NewCartService(repo)
+	ctx := context.Background()
+
+	repo.On("RemoveFromCart", ctx, "user1", "item1", "").Return(cstErrors.NotFoundError)
+
+	err := svc.RemoveItem(ctx, "user1", "item1", "")
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for CartService.GetCart ---
+
+func TestCartService_GetCart_Error(t *testing.T) {
+	repo := new(MockCartRepository)
+	svc := NewCartService(repo)
+	ctx := context.Background()
+
+	repo.On("GetCartItems", ctx, "user1").Return(nil, errors.New("db error"))
+
+	cart, err := svc.GetCart(ctx, "user1")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "CartService.GetCart")
+	assert.Nil(t, cart)
+	repo.AssertExpectations(t)
+}
+
+func TestCartService_GetCart_Empty(t *testing.T) {
+	repo := new(MockCartRepository)
+	svc := NewCartService(repo)
+	ctx := context.Background()
+
+	repo.On("GetCartItems", ctx, "user1").Return(nil, nil)
+
+	cart, err := svc.GetCart(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.CartResponse{Items: []*model.CartLine{}}, cart)
+	repo.AssertExpectations(t)
+}
+
+func TestCartService_GetCart_Success(t *testing.T) {
+	repo := new(MockCartRepository)
+	svc := NewCartService(repo)
+	ctx := context.Background()
+
+	salePrice := 250
+	items := []*model.CartItem{
+		{Merch: &model.Merch{Id: "item1", Name: "cup", Price: 20}, Quantity: 3},
+		{Merch: &model.Merch{Id: "item2", Name: "hoody", Price: 300, Sale: &model.PriceSchedule{SalePrice: &salePrice}},
+			Variant: &model.MerchVariant{Id: "var1", Size: "XL", Color: "black"}, Quantity: 1},
+	}
+	repo.On("GetCartItems", ctx, "user1").Return(items, nil)
+
+	cart, err := svc.GetCart(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 310, cart.Total)
+	assert.Equal(t, &model.CartLine{Item: "item2", Name: "hoody", Variant: "XL / black", VariantId: "var1",
+		Quantity: 1, Price: 250, LineTotal: 250}, cart.Items[1])
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"time"
+)
+
+const coinsExpiredReason = "coins expired"
+
+// AllowancePolicy describes periodic allowance top-ups
+type AllowancePolicy struct {
+	Amount int           // coins credited each period, 0 disables allowances
+	Period string        // model.AllowancePeriodWeek or model.AllowancePeriodMonth
+	TTL    time.Duration // lifetime of allowance coins from the period start, 0 means until the period ends
+}
+
+type CoinRepository interface {
+	IssueAllowance(ctx context.Context, amount int, periodStart, expiresAt time.Time, reason string) (int, error)
+	ExpireCoinLots(ctx context.Context, now time.Time, reason string) (int, error)
+	GetExpiringCoins(ctx context.Context, userId string) ([]*model.ExpiringCoins, error)
+}
+
+type CoinService struct {
+	repo      CoinRepository
+	allowance AllowancePolicy
+}
+
+func NewCoinService(repo CoinRepository, allowance AllowancePolicy) *CoinService {
+	return &CoinService{repo: repo, allowance: allowance}
+}
+
+// IssueAllowance credits the allowance for the period containing now to every user who has not
+// got it yet. Returns the number of credited users.
+func (c *CoinService) IssueAllowance(ctx context.Context, now time.Time) (int, error) {
+	const op = "CoinService.IssueAllowance"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if c.allowance.Amount <= 0 {
+		return 0, nil
+	}
+	start, end := model.AllowancePeriod(now, c.allowance.Period)
+	expiresAt := end
+	if c.allowance.TTL > 0 {
+		expiresAt = start.Add(c.allowance.TTL)
+	}
+	if !expiresAt.After(now) {
+		return 0, nil
+	}
+
+	reason := fmt.Sprintf("%s allowance since %s", c.allowance.Period, start.Format(time.DateOnly))
+	issued, err := c.repo.IssueAllowance(ctx, c.allowance.Amount, start, expiresAt, reason)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return issued, nil
+}
+
+// ExpireCoins removes unspent coins expired by now from balances. Returns the number of expired coins.
+func (c *CoinService) ExpireCoins(ctx context.Context, now time.Time) (int, error) {
+	const op = "CoinService.ExpireCoins"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	expired, err := c.repo.ExpireCoinLots(ctx, now, coinsExpiredReason)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return expired, nil
+}
+
+func (c *CoinService) GetExpiringCoins(ctx context.Context, userId string) ([]*model.ExpiringCoins, error) {
+	const op = "CoinService.GetExpiringCoins"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	coins, err := c.repo.GetExpiringCoins(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return coins, nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+)
+
+type MockCoinRepository struct {
+	mock.Mock
+}
+
+func (m *MockCoinRepository) IssueAllowance(ctx context.Context, amount int, periodStart, expiresAt time.Time, reason string) (int, error) {
+	args := m.Called(ctx, amount, periodStart, expiresAt, reason)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockCoinRepository) ExpireCoinLots(ctx context.Context, now time.Time, reason string) (int, error) {
+	args := m.Called(ctx, now, reason)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockCoinRepository) GetExpiringCoins(ctx context.Context, userId string) ([]*model.ExpiringCoins, error) {
+	args := m.Called(ctx, userId)
+	if coins := args.Get(0); coins != nil {
+		return coins.([]*model.ExpiringCoins), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// --- Tests for CoinService.IssueAllowance ---
+
+func TestCoinService_IssueAllowance_Month(t *testing.T) {
+	mockRepo := new(MockCoinRepository)
+	cs := NewCoinService(mockRepo, AllowancePolicy{Amount: 100, Period: model.AllowancePeriodMonth})
+	ctx := context.Background()
+
+	now := time.Date(2025, 3, 17, 12, 0, 0, 0, time.UTC)
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("IssueAllowance", ctx, 100, start, end, "month allowance since 2025-03-01").Return(42, nil)
+
+	issued, err := cs.IssueAllowance(ctx, now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 42, issued)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCoinService_IssueAllowance_WeekWithTTL(t *testing.T) {
+	mockRepo := new(MockCoinRepository)
+	cs := NewCoinService(mockRepo, AllowancePolicy{Amount: 20, Period: model.AllowancePeriodWeek, TTL: 14 * 24 * time.Hour})
+	ctx := context.Background()
+
+	// Sunday belongs to the week started on Monday
+	now := time.Date(2025, 3, 16, 23, 0, 0, 0, time.UTC)
+	start := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("IssueAllowance", ctx, 20, start, start.AddDate(0, 0, 14), "week allowance since 2025-03-10").Return(3, nil)
+
+	issued, err := cs.IssueAllowance(ctx, now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, issued)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCoinService_IssueAllowance_ExpiredBeforeNow(t *testing.T) {
+	mockRepo := new(MockCoinRepository)
+	cs := NewCoinService(mockRepo, AllowancePolicy{Amount: 20, Period: model.AllowancePeriodMonth, TTL: 24 * time.Hour})
+
+	issued, err := cs.IssueAllowance(context.Background(), time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC))
+
+	assert.NoError(t, err)
+	assert.Zero(t, issued)
+	mockRepo.AssertNotCalled(t, "IssueAllowance")
+}
+
+func TestCoinService_IssueAllowance_Disabled(t *testing.T) {
+	mockRepo := new(MockCoinRepository)
+	cs := NewCoinService(mockRepo, AllowancePolicy{Period: model.AllowancePeriodMonth})
+
+	issued, err := cs.IssueAllowance(context.Background(), time.Now())
+
+	assert.NoError(t, err)
+	assert.Zero(t, issued)
+	mockRepo.AssertNotCalled(t, "IssueAllowance")
+}
+
+func TestCoinService_IssueAllowance_Error(t *testing.T) {
+	mockRepo := new(MockCoinRepository)
+	cs := NewCoinService(mockRepo, AllowancePolicy{Amount: 100, Period: model.AllowancePeriodMonth})
+	ctx := context.Background()
+
+	mockRepo.On("IssueAllowance", ctx, 100, mock.Anything, mock.Anything, mock.Anything).Return(0, errors.New("db error"))
+
+	_, err := cs.IssueAllowance(ctx, time.Now())
+
+	assert.Error(t, err)
+}
+
+// --- Tests for CoinService.ExpireCoins ---
+
+func TestCoinService_ExpireCoins_Success(t *testing.T) {
+	mockRepo := new(MockCoinRepository)
+	cs := NewCoinService(mockRepo, AllowancePolicy{})
+	ctx := context.Background()
+
+	now := time.Now()
+	mockRepo.On("ExpireCoinLots", ctx, now, coinsExpiredReason).Return(150, nil)
+
+	expired, err := cs.ExpireCoins(ctx, now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 150, expired)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCoinService_ExpireCoins_Error(t *testing.T) {
+	mockRepo := new(MockCoinRepository)
+	cs := NewCoinService(mockRepo, AllowancePolicy{})
+	ctx := context.Background()
+
+	now := time.Now()
+	mockRepo.On("ExpireCoinLots", ctx, now, coinsExpiredReason).Return(0, errors.New("db error"))
+
+	_, err := cs.ExpireCoins(ctx, now)
+
+	assert.Error(t, err)
+}
+
+// --- Tests for CoinService.GetExpiringCoins ---
+
+func TestCoinService_GetExpiringCoins_Success(t *testing.T) {
+	mockRepo := new(MockCoinRepository)
+	cs := NewCoinService(mockRepo, AllowancePolicy{})
+	ctx := context.Background()
+
+	coins := []*model.ExpiringCoins{{Amount: 40, ExpiresAt: time.Now().Add(time.Hour)}}
+	mockRepo.On("GetExpiringCoins", ctx, "user1").Return(coins, nil)
+
+	result, err := cs.GetExpiringCoins(ctx, "user1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, coins, result)
+}
+
+func TestCoinService_GetExpiringCoins_Error(t *testing.T) {
+	mockRepo := new(MockCoinRepository)
+	cs := NewCoinService(mockRepo, AllowancePolicy{})
+	ctx := context.Background()
+
+	mockRepo.On("GetExpiringCoins", ctx, "user1").Return(nil, errors.New("db error"))
+
+	result, err := cs.GetExpiringCoins(ctx, "user1")
+
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/logger"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const readinessCheckTimeout = 2 * time.Second
+
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	CheckSchema(ctx context.Context) error
+}
+
+// HealthService checks dependencies of the app for the readiness probe
+type HealthService struct {
+	repo         HealthRepository
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(repo HealthRepository) *HealthService {
+	return &HealthService{repo: repo}
+}
+
+// SetShuttingDown makes the app report not ready, it is called when graceful shutdown starts
+func (h *HealthService) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Readiness checks that the database is reachable and migrations are applied. The response shows
+// which dependency is down, check errors are only logged since the probe is not authenticated.
+func (h *HealthService) Readiness(ctx context.Context) *model.ReadinessResponse {
+	const op = "HealthService.Readiness"
+
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	resp := &model.ReadinessResponse{
+		Status:       model.HealthStatusReady,
+		Dependencies: make(map[string]*model.DependencyStatus, 2),
+	}
+	check := func(name string, fn func(ctx context.Context) error) bool {
+		status := &model.DependencyStatus{Status: model.HealthStatusUp}
+		if err := fn(ctx); err != nil {
+			status.Status = model.HealthStatusDown
+			logger.FromContext(ctx).Warn("readiness check failed", slog.String("op", op),
+				slog.String("dependency", name), slog.Any("error", err))
+			resp.Status = model.HealthStatusNotReady
+		}
+		resp.Dependencies[name] = status
+		return status.Status == model.HealthStatusUp
+	}
+
+	// There is no point in checking the schema of an unreachable database
+	if check("database", h.repo.Ping) {
+		check("migrations", h.repo.CheckSchema)
+	}
+	if h.shuttingDown.Load() {
+		resp.Status = model.HealthStatusShuttingDown
+	}
+	return resp
+}
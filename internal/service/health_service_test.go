@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+)
+
+type MockHealthRepository struct {
+	mock.Mock
+}
+
+func (m *MockHealthRepository) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockHealthRepository) CheckSchema(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+// --- Tests for HealthService.Readiness ---
+
+func TestHealthService_Readiness_Ready(t *testing.T) {
+	mockRepo := new(MockHealthRepository)
+	hs := NewHealthService(mockRepo)
+
+	mockRepo.On("Ping", mock.Anything).Return(nil)
+	mockRepo.On("CheckSchema", mock.Anything).Return(nil)
+
+	resp := hs.Readiness(context.Background())
+
+	assert.True(t, resp.Ready())
+	assert.Equal(t, model.HealthStatusUp, resp.Dependencies["database"].Status)
+	assert.Equal(t, model.HealthStatusUp, resp.Dependencies["migrations"].Status)
+}
+
+func TestHealthService_Readiness_DatabaseDown(t *testing.T) {
+	mockRepo := new(MockHealthRepository)
+	hs := NewHealthService(mockRepo)
+
+	mockRepo.On("Ping", mock.Anything).Return(errors.New("connection refused"))
+
+	resp := hs.Readiness(context.Background())
+
+	assert.False(t, resp.Ready())
+	assert.Equal(t, model.HealthStatusNotReady, resp.Status)
+	assert.Equal(t, &model.DependencyStatus{Status: model.HealthStatusDown}, resp.Dependencies["database"])
+	assert.NotContains(t, resp.Dependencies, "migrations")
+	mockRepo.AssertNotCalled(t, "CheckSchema", mock.Anything)
+}
+
+func TestHealthService_Readiness_MigrationsMissing(t *testing.T) {
+	mockRepo := new(MockHealthRepository)
+	hs := NewHealthService(mockRepo)
+
+	mockRepo.On("Ping", mock.Anything).Return(nil)
+	mockRepo.On("CheckSchema", mock.Anything).Return(errors.New("missing tables: coin_lots"))
+
+	resp := hs.Readiness(context.Background())
+
+	assert.False(t, resp.Ready())
+	assert.Equal(t, model.HealthStatusUp, resp.Dependencies["database"].Status)
+	assert.Equal(t, model.HealthStatusDown, resp.Dependencies["migrations"].Status)
+}
+
+func TestHealthService_Readiness_ShuttingDown(t *testing.T) {
+	mockRepo := new(MockHealthRepository)
+	hs := NewHealthService(mockRepo)
+
+	mockRepo.On("Ping", mock.Anything).Return(nil)
+	mockRepo.On("CheckSchema", mock.Anything).Return(nil)
+
+	hs.SetShuttingDown()
+	resp := hs.Readiness(context.Background())
+
+	assert.False(t, resp.Ready())
+	assert.Equal(t, model.HealthStatusShuttingDown, resp.Status)
+	assert.Equal(t, model.HealthStatusUp, resp.Dependencies["database"].Status)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"sync"
+	"time"
+)
+
+const maxLeaderboardLimit = 100
+
+type LeaderboardRepository interface {
+	GetLeaderboard(ctx context.Context, metric string, since *time.Time, limit int) ([]*model.LeaderboardEntry, error)
+}
+
+type leaderboardKey struct {
+	metric string
+	window string
+	limit  int
+}
+
+type cachedLeaderboard struct {
+	resp      *model.LeaderboardResponse
+	expiresAt time.Time
+}
+
+// LeaderboardService ranks users by coins received, sent, spent and by the number of colleagues thanked.
+// Aggregates are cached in memory for cacheTTL, zero TTL disables the cache.
+type LeaderboardService struct {
+	repo     LeaderboardRepository
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[leaderboardKey]cachedLeaderboard
+}
+
+func NewLeaderboardService(repo LeaderboardRepository, cacheTTL time.Duration) *LeaderboardService {
+	return &LeaderboardService{
+		repo:     repo,
+		cacheTTL: cacheTTL,
+		cache:    make(map[leaderboardKey]cachedLeaderboard),
+	}
+}
+
+// GetLeaderboard returns top users by the metric within the calendar week, month or all time
+func (l *LeaderboardService) GetLeaderboard(ctx context.Context, metric, window string, limit int) (*model.LeaderboardResponse, error) {
+	const op = "LeaderboardService.GetLeaderboard"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if !model.IsValidLeaderboardMetric(metric) || !model.IsValidLeaderboardWindow(window) {
+		return nil, cstErrors.BadRequestDataError
+	}
+	if limit <= 0 || limit > maxLeaderboardLimit {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	now := time.Now()
+	key := leaderboardKey{metric: metric, window: window, limit: limit}
+	if resp := l.cached(key, now); resp != nil {
+		return resp, nil
+	}
+
+	since := model.LeaderboardSince(now, window)
+	entries, err := l.repo.GetLeaderboard(ctx, metric, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if entries == nil {
+		entries = []*model.LeaderboardEntry{}
+	}
+
+	resp := &model.LeaderboardResponse{
+		Metric:  metric,
+		Window:  window,
+		Since:   since,
+		Entries: entries,
+	}
+	l.store(key, resp, now)
+	return resp, nil
+}
+
+func (l *LeaderboardService) cached(key leaderboardKey, now time.Time) *model.LeaderboardResponse {
+	if l.cacheTTL <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.cache[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		return nil
+	}
+	// A cached week or month leaderboard is stale once the next period has started
+	if since := model.LeaderboardSince(now, key.window); since != nil && !since.Equal(*entry.resp.Since) {
+		return nil
+	}
+	return entry.resp
+}
+
+func (l *LeaderboardService) store(key leaderboardKey, resp *model.LeaderboardResponse, now time.Time) {
+	if l.cacheTTL <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cache[key] = cachedLeaderboard{resp: resp, expiresAt: now.Add(l.cacheTTL)}
+}
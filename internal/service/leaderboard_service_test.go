@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+)
+
+type MockLeaderboardRepository struct {
+	mock.Mock
+}
+
+func (m *MockLeaderboardRepository) GetLeaderboard(ctx context.Context, metric string, since *time.Time, limit int) ([]*model.LeaderboardEntry, error) {
+	args := m.Called(ctx, metric, since, limit)
+	if entries := args.Get(0); entries != nil {
+		return entries.([]*model.LeaderboardEntry), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// --- Tests for LeaderboardService.GetLeaderboard ---
+
+func TestLeaderboardService_GetLeaderboard_Week(t *testing.T) {
+	mockRepo := new(MockLeaderboardRepository)
+	ls := NewLeaderboardService(mockRepo, 0)
+	ctx := context.Background()
+
+	entries := []*model.LeaderboardEntry{{Rank: 1, User: "bob", Value: 300}, {Rank: 2, User: "alice", Value: 100}}
+	since := model.LeaderboardSince(time.Now(), model.LeaderboardWindowWeek)
+	mockRepo.On("GetLeaderboard", ctx, model.LeaderboardReceived, since, 10).Return(entries, nil)
+
+	resp, err := ls.GetLeaderboard(ctx, model.LeaderboardReceived, model.LeaderboardWindowWeek, 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.LeaderboardReceived, resp.Metric)
+	assert.Equal(t, model.LeaderboardWindowWeek, resp.Window)
+	assert.Equal(t, since, resp.Since)
+	assert.Equal(t, entries, resp.Entries)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLeaderboardService_GetLeaderboard_AllTime(t *testing.T) {
+	mockRepo := new(MockLeaderboardRepository)
+	ls := NewLeaderboardService(mockRepo, 0)
+	ctx := context.Background()
+
+	mockRepo.On("GetLeaderboard", ctx, model.LeaderboardThanked, (*time.Time)(nil), 5).Return(nil, nil)
+
+	resp, err := ls.GetLeaderboard(ctx, model.LeaderboardThanked, model.LeaderboardWindowAll, 5)
+
+	assert.NoError(t, err)
+	assert.Nil(t, resp.Since)
+	assert.NotNil(t, resp.Entries)
+	assert.Empty(t, resp.Entries)
+}
+
+func TestLeaderboardService_GetLeaderboard_BadParams(t *testing.T) {
+	mockRepo := new(MockLeaderboardRepository)
+	ls := NewLeaderboardService(mockRepo, 0)
+	ctx := context.Background()
+
+	tests := []struct {
+		metric string
+		window string
+		limit  int
+	}{
+		{"karma", model.LeaderboardWindowWeek, 10},
+		{model.LeaderboardSent, "year", 10},
+		{model.LeaderboardSent, model.LeaderboardWindowWeek, 0},
+		{model.LeaderboardSent, model.LeaderboardWindowWeek, maxLeaderboardLimit + 1},
+	}
+	for _, tt := range tests {
+		_, err := ls.GetLeaderboard(ctx, tt.metric, tt.window, tt.limit)
+		assert.Equal(t, cstErrors.BadRequestDataError, err)
+	}
+	mockRepo.AssertNotCalled(t, "GetLeaderboard")
+}
+
+func TestLeaderboardService_GetLeaderboard_Cached(t *testing.T) {
+	mockRepo := new(MockLeaderboardRepository)
+	ls := NewLeaderboardService(mockRepo, time.Minute)
+	ctx := context.Background()
+
+	entries := []*model.LeaderboardEntry{{Rank: 1, User: "bob", Value: 300}}
+	mockRepo.On("GetLeaderboard", ctx, model.LeaderboardSent, mock.Anything, 10).Return(entries, nil).Once()
+	mockRepo.On("GetLeaderboard", ctx, model.LeaderboardSent, mock.Anything, 20).Return(entries, nil).Once()
+
+	first, err := ls.GetLeaderboard(ctx, model.LeaderboardSent, model.LeaderboardWindowMonth, 10)
+	assert.NoError(t, err)
+	second, err := ls.GetLeaderboard(ctx, model.LeaderboardSent, model.LeaderboardWindowMonth, 10)
+	assert.NoError(t, err)
+	_, err = ls.GetLeaderboard(ctx, model.LeaderboardSent, model.LeaderboardWindowMonth, 20)
+	assert.NoError(t, err)
+
+	assert.Same(t, first, second)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLeaderboardService_GetLeaderboard_CacheDisabled(t *testing.T) {
+	mockRepo := new(MockLeaderboardRepository)
+	ls := NewLeaderboardService(mockRepo, 0)
+	ctx := context.Background()
+
+	mockRepo.On("GetLeaderboard", ctx, model.LeaderboardSpent, mock.Anything, 10).Return(nil, nil).Twice()
+
+	_, _ = ls.GetLeaderboard(ctx, model.LeaderboardSpent, model.LeaderboardWindowAll, 10)
+	_, _ = ls.GetLeaderboard(ctx, model.LeaderboardSpent, model.LeaderboardWindowAll, 10)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLeaderboardService_GetLeaderboard_Error(t *testing.T) {
+	mockRepo := new(MockLeaderboardRepository)
+	ls := NewLeaderboardService(mockRepo, time.Minute)
+	ctx := context.Background()
+
+	mockRepo.On("GetLeaderboard", ctx, model.LeaderboardReceived, mock.Anything, 10).Return(nil, errors.New("db error")).Twice()
+
+	_, err := ls.GetLeaderboard(ctx, model.LeaderboardReceived, model.LeaderboardWindowWeek, 10)
+	assert.Error(t, err)
+	assert.False(t, cstErrors.IsCustomError(err))
+
+	// Failures are not cached
+	_, err = ls.GetLeaderboard(ctx, model.LeaderboardReceived, model.LeaderboardWindowWeek, 10)
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,480 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"strings"
+	"time"
+)
+
+type MerchRepository interface {
+	GetMerchByName(ctx context.Context, name string) (*model.Merch, error)
+
+	CreateMerch(ctx context.Context, merch *model.Merch) (*model.Merch, error)
+	SetMerchSelling(ctx context.Context, merchId string, isSelling bool) error
+	SetMerchStock(ctx context.Context, merchId string, stock *int) error
+	SetMerchMaxPerUser(ctx context.Context, merchId string, maxPerUser *int) error
+	RestockMerch(ctx context.Context, merchId string, quantity int) (int, error)
+	GetLowStockMerch(ctx context.Context, threshold int) ([]*model.Merch, error)
+	GetCatalog(ctx context.Context, category string) ([]*model.Merch, error)
+	SetMerchDetails(ctx context.Context, merchId, description, category, imageURL string) error
+	CreateMerchVariant(ctx context.Context, variant *model.MerchVariant) (*model.MerchVariant, error)
+	GetMerchVariants(ctx context.Context, merchIds []string) ([]*model.MerchVariant, error)
+	SetVariantStock(ctx context.Context, id string, stock *int) error
+
+	CreatePriceSchedule(ctx context.Context, schedule *model.PriceSchedule) (*model.PriceSchedule, error)
+	GetPriceSchedules(ctx context.Context, merchId string) ([]*model.PriceSchedule, error)
+	DeletePriceSchedule(ctx context.Context, id int64) error
+
+	CreatePromoCode(ctx context.Context, promo *model.PromoCode) (*model.PromoCode, error)
+	GetPromoCodes(ctx context.Context) ([]*model.PromoCode, error)
+}
+
+type MerchService struct {
+	repo MerchRepository
+}
+
+func NewMerchService(repo MerchRepository) *MerchService {
+	return &MerchService{repo: repo}
+}
+
+// CreateMerch adds an item to the catalog, nil stock means the item is never sold out
+func (m *MerchService) CreateMerch(ctx context.Context, name string, price int, isSelling bool, stock *int) (*model.Merch, error) {
+	const op = "MerchService.CreateMerch"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if name == "" || price <= 0 || (stock != nil && *stock < 0) {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	merch := &model.Merch{
+		Name:      name,
+		Price:     price,
+		IsSelling: isSelling,
+		Stock:     stock,
+	}
+	merch, err := m.repo.CreateMerch(ctx, merch)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return merch, nil
+}
+
+func (m *MerchService) SetMerchSelling(ctx context.Context, name string, isSelling bool) error {
+	const op = "MerchService.SetMerchSelling"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	merch, err := m.repo.GetMerchByName(ctx, name)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = m.repo.SetMerchSelling(ctx, merch.Id, isSelling); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SetStock overwrites the stock of the item, nil makes it unlimited
+func (m *MerchService) SetStock(ctx context.Context, name string, stock *int) error {
+	const op = "MerchService.SetStock"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if stock != nil && *stock < 0 {
+		return cstErrors.BadRequestDataError
+	}
+
+	merch, err := m.repo.GetMerchByName(ctx, name)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = m.repo.SetMerchStock(ctx, merch.Id, stock); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SetMaxPerUser limits how many items one user can buy, nil removes the limit
+func (m *MerchService) SetMaxPerUser(ctx context.Context, name string, maxPerUser *int) error {
+	const op = "MerchService.SetMaxPerUser"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if maxPerUser != nil && *maxPerUser <= 0 {
+		return cstErrors.BadRequestDataError
+	}
+
+	merch, err := m.repo.GetMerchByName(ctx, name)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = m.repo.SetMerchMaxPerUser(ctx, merch.Id, maxPerUser); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (m *MerchService) Restock(ctx context.Context, name string, quantity int) (int, error) {
+	const op = "MerchService.Restock"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if quantity <= 0 {
+		return 0, cstErrors.BadRequestDataError
+	}
+
+	merch, err := m.repo.GetMerchByName(ctx, name)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stock, err := m.repo.RestockMerch(ctx, merch.Id, quantity)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return stock, nil
+}
+
+func (m *MerchService) GetLowStock(ctx context.Context, threshold int) ([]*model.Merch, error) {
+	const op = "MerchService.GetLowStock"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if threshold < 0 {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	merchList, err := m.repo.GetLowStockMerch(ctx, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return merchList, nil
+}
+
+// GetCatalog lists merch on sale with the prices buyers pay right now and its variants,
+// empty category lists all merch
+func (m *MerchService) GetCatalog(ctx context.Context, category string) ([]*model.CatalogItem, error) {
+	const op = "MerchService.GetCatalog"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	merchList, err := m.repo.GetCatalog(ctx, category)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var withVariants []string
+	for _, merch := range merchList {
+		if merch.HasVariants {
+			withVariants = append(withVariants, merch.Id)
+		}
+	}
+	variants := make(map[string][]*model.CatalogVariant, len(withVariants))
+	if len(withVariants) > 0 {
+		list, err := m.repo.GetMerchVariants(ctx, withVariants)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, v := range list {
+			variants[v.MerchId] = append(variants[v.MerchId], &model.CatalogVariant{
+				Id:    v.Id,
+				Size:  v.Size,
+				Color: v.Color,
+				Stock: v.Stock,
+			})
+		}
+	}
+
+	catalog := make([]*model.CatalogItem, 0, len(merchList))
+	for _, merch := range merchList {
+		item := &model.CatalogItem{
+			Id:             merch.Id,
+			Name:           merch.Name,
+			Description:    merch.Description,
+			Category:       merch.Category,
+			ImageURL:       merch.ImageURL,
+			Price:          merch.Price,
+			EffectivePrice: merch.EffectivePrice(),
+			Stock:          merch.Stock,
+			Variants:       variants[merch.Id],
+		}
+		if merch.Sale != nil {
+			item.SaleEndsAt = &merch.Sale.EndsAt
+		}
+		catalog = append(catalog, item)
+	}
+	return catalog, nil
+}
+
+func (m *MerchService) SetDetails(ctx context.Context, name string, req *model.MerchDetailsRequest) error {
+	const op = "MerchService.SetDetails"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	merch, err := m.repo.GetMerchByName(ctx, name)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = m.repo.SetMerchDetails(ctx, merch.Id, strings.TrimSpace(req.Description),
+		strings.TrimSpace(req.Category), strings.TrimSpace(req.ImageURL))
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// AddVariant adds a size or color to the merch, from then on the merch is bought by variant
+func (m *MerchService) AddVariant(ctx context.Context, name string, req *model.MerchVariantRequest) (*model.MerchVariant, error) {
+	const op = "MerchService.AddVariant"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	size, color := strings.TrimSpace(req.Size), strings.TrimSpace(req.Color)
+	if (size == "" && color == "") || (req.Stock != nil && *req.Stock < 0) {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	merch, err := m.repo.GetMerchByName(ctx, name)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	variant := &model.MerchVariant{
+		MerchId: merch.Id,
+		Size:    size,
+		Color:   color,
+		Stock:   req.Stock,
+	}
+	variant, err = m.repo.CreateMerchVariant(ctx, variant)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return variant, nil
+}
+
+func (m *MerchService) SetVariantStock(ctx context.Context, variantId string, stock *int) error {
+	const op = "MerchService.SetVariantStock"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if stock != nil && *stock < 0 {
+		return cstErrors.BadRequestDataError
+	}
+
+	if err := m.repo.SetVariantStock(ctx, variantId, stock); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// CreatePriceSchedule plans a sale of the item, either a fixed sale price or a percent discount
+func (m *MerchService) CreatePriceSchedule(ctx context.Context, name string, req *model.PriceScheduleRequest) (*model.PriceSchedule, error) {
+	const op = "MerchService.CreatePriceSchedule"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if !req.EndsAt.After(req.StartsAt) || (req.SalePrice == nil) == (req.PercentOff == nil) {
+		return nil, cstErrors.BadRequestDataError
+	}
+	if req.SalePrice != nil && *req.SalePrice <= 0 {
+		return nil, cstErrors.BadRequestDataError
+	}
+	if req.PercentOff != nil && (*req.PercentOff < 1 || *req.PercentOff > 99) {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	merch, err := m.repo.GetMerchByName(ctx, name)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// A sale price must be a discount
+	if req.SalePrice != nil && *req.SalePrice >= merch.Price {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	schedule := &model.PriceSchedule{
+		MerchId:    merch.Id,
+		StartsAt:   req.StartsAt,
+		EndsAt:     req.EndsAt,
+		SalePrice:  req.SalePrice,
+		PercentOff: req.PercentOff,
+	}
+	schedule, err = m.repo.CreatePriceSchedule(ctx, schedule)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return schedule, nil
+}
+
+func (m *MerchService) GetPriceSchedules(ctx context.Context, name string) ([]*model.PriceSchedule, error) {
+	const op = "MerchService.GetPriceSchedules"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	merch, err := m.repo.GetMerchByName(ctx, name)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	schedules, err := m.repo.GetPriceSchedules(ctx, merch.Id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return schedules, nil
+}
+
+func (m *MerchService) DeletePriceSchedule(ctx context.Context, id int64) error {
+	const op = "MerchService.DeletePriceSchedule"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := m.repo.DeletePriceSchedule(ctx, id); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// CreatePromoCode registers a promo code, codes are case-insensitive and stored in upper case.
+// An empty merch list makes the code applicable to any item.
+func (m *MerchService) CreatePromoCode(ctx context.Context, req *model.PromoCodeRequest) (*model.PromoCode, error) {
+	const op = "MerchService.CreatePromoCode"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if code == "" || req.DiscountValue <= 0 {
+		return nil, cstErrors.BadRequestDataError
+	}
+	if req.DiscountType != model.PromoDiscountPercent && req.DiscountType != model.PromoDiscountFixed {
+		return nil, cstErrors.BadRequestDataError
+	}
+	if req.DiscountType == model.PromoDiscountPercent && req.DiscountValue >= 100 {
+		return nil, cstErrors.BadRequestDataError
+	}
+	if (req.MaxUses != nil && *req.MaxUses <= 0) || (req.MaxUsesPerUser != nil && *req.MaxUsesPerUser <= 0) {
+		return nil, cstErrors.BadRequestDataError
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	merchIds := make([]string, 0, len(req.Merch))
+	for _, name := range req.Merch {
+		merch, err := m.repo.GetMerchByName(ctx, name)
+		if err != nil {
+			if cstErrors.IsCustomError(err) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		merchIds = append(merchIds, merch.Id)
+	}
+
+	promo := &model.PromoCode{
+		Code:           code,
+		DiscountType:   req.DiscountType,
+		DiscountValue:  req.DiscountValue,
+		MaxUses:        req.MaxUses,
+		MaxUsesPerUser: req.MaxUsesPerUser,
+		ExpiresAt:      req.ExpiresAt,
+		MerchIds:       merchIds,
+	}
+	promo, err := m.repo.CreatePromoCode(ctx, promo)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return promo, nil
+}
+
+func (m *MerchService) GetPromoCodes(ctx context.Context) ([]*model.PromoCode, error) {
+	const op = "MerchService.GetPromoCodes"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	promos, err := m.repo.GetPromoCodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return promos, nil
+}
@@ -0,0 +1,651 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+)
+
+type MockMerchRepository struct {
+	mock.Mock
+}
+
+func (m *MockMerchRepository) GetMerchByName(ctx context.Context, name string) (*model.Merch, error) {
+	args := m.Called(ctx, name)
+	if merch := args.Get(0); merch != nil {
+		return merch.(*model.Merch), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMerchRepository) CreateMerch(ctx context.Context, merch *model.Merch) (*model.Merch, error) {
+	args := m.Called(ctx, merch)
+	if mr := args.Get(0); mr != nil {
+		return mr.(*model.Merch), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMerchRepository) SetMerchSelling(ctx context.Context, merchId string, isSelling bool) error {
+	args := m.Called(ctx, merchId, isSelling)
+	return args.Error(0)
+}
+
+func (m *MockMerchRepository) SetMerchStock(ctx context.Context, merchId string, stock *int) error {
+	args := m.Called(ctx, merchId, stock)
+	return args.Error(0)
+}
+
+func (m *MockMerchRepository) SetMerchMaxPerUser(ctx context.Context, merchId string, maxPerUser *int) error {
+	args := m.Called(ctx, merchId, maxPerUser)
+	return args.Error(0)
+}
+
+func (m *MockMerchRepository) RestockMerch(ctx context.Context, merchId string, quantity int) (int, error) {
+	args := m.Called(ctx, merchId, quantity)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockMerchRepository) GetLowStockMerch(ctx context.Context, threshold int) ([]*model.Merch, error) {
+	args := m.Called(ctx, threshold)
+	if list := args.Get(0); list != nil {
+		return list.([]*model.Merch), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMerchRepository) GetCatalog(ctx context.Context, category string) ([]*model.Merch, error) {
+	args := m.Called(ctx, category)
+	if list := args.Get(0); list != nil {
+		return list.([]*model.Merch), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMerchRepository) SetMerchDetails(ctx context.Context, merchId, description, category, imageURL string) error {
+	args := m.Called(ctx, merchId, description, category, imageURL)
+	return args.Error(0)
+}
+
+func (m *MockMerchRepository) CreateMerchVariant(ctx context.Context, variant *model.MerchVariant) (*model.MerchVariant, error) {
+	args := m.Called(ctx, variant)
+	if v := args.Get(0); v != nil {
+		return v.(*model.MerchVariant), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMerchRepository) GetMerchVariants(ctx context.Context, merchIds []string) ([]*model.MerchVariant, error) {
+	args := m.Called(ctx, merchIds)
+	if list := args.Get(0); list != nil {
+		return list.([]*model.MerchVariant), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMerchRepository) SetVariantStock(ctx context.Context, id string, stock *int) error {
+	args := m.Called(ctx, id, stock)
+	return args.Error(0)
+}
+
+func (m *MockMerchRepository) CreatePriceSchedule(ctx context.Context, schedule *model.PriceSchedule) (*model.PriceSchedule, error) {
+	args := m.Called(ctx, schedule)
+	if s := args.Get(0); s != nil {
+		return s.(*model.PriceSchedule), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMerchRepository) GetPriceSchedules(ctx context.Context, merchId string) ([]*model.PriceSchedule, error) {
+	args := m.Called(ctx, merchId)
+	if list := args.Get(0); list != nil {
+		return list.([]*model.PriceSchedule), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMerchRepository) DeletePriceSchedule(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockMerchRepository) CreatePromoCode(ctx context.Context, promo *model.PromoCode) (*model.PromoCode, error) {
+	args := m.Called(ctx, promo)
+	if p := args.Get(0); p != nil {
+		return p.(*model.PromoCode), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMerchRepository) GetPromoCodes(ctx context.Context) ([]*model.PromoCode, error) {
+	args := m.Called(ctx)
+	if list := args.Get(0); list != nil {
+		return list.([]*model.PromoCode), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// --- Tests for MerchService.CreateMerch ---
+
+func TestMerchService_CreateMerch_BadRequest(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	merch, err := svc.CreateMerch(ctx, "", 100, true, nil)
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, merch)
+
+	merch, err = svc.CreateMerch(ctx, "sticker", 0, true, nil)
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, merch)
+
+	negative := -1
+	merch, err = svc.CreateMerch(ctx, "sticker", 5, true, &negative)
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, merch)
+}
+
+func TestMerchService_CreateMerch_AlreadyExists(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("CreateMerch", ctx, mock.AnythingOfType("*model.Merch")).Return(nil, cstErrors.MerchAlreadyExistsError)
+
+	merch, err := svc.CreateMerch(ctx, "cup", 20, true, nil)
+	assert.Equal(t, cstErrors.MerchAlreadyExistsError, err)
+	assert.Nil(t, merch)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_CreateMerch_Success(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	created := &model.Merch{Id: "item1", Name: "sticker", Price: 5, IsSelling: true}
+	repo.On("CreateMerch", ctx, mock.MatchedBy(func(m *model.Merch) bool {
+		return m.Name == "sticker" && m.Price == 5 && m.IsSelling
+	})).Return(created, nil)
+
+	merch, err := svc.CreateMerch(ctx, "sticker", 5, true, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, created, merch)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.SetMerchSelling ---
+
+func TestMerchService_SetMerchSelling_NotFound(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchByName", ctx, "ghost").Return(nil, cstErrors.NotFoundError)
+
+	err := svc.SetMerchSelling(ctx, "ghost", false)
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_SetMerchSelling_UpdateError(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchByName", ctx, "cup").Return(&model.Merch{Id: "item1", Name: "cup"}, nil)
+	repo.On("SetMerchSelling", ctx, "item1", false).Return(errors.New("update error"))
+
+	err := svc.SetMerchSelling(ctx, "cup", false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "MerchService.SetMerchSelling")
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_SetMerchSelling_Success(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchByName", ctx, "cup").Return(&model.Merch{Id: "item1", Name: "cup"}, nil)
+	repo.On("SetMerchSelling", ctx, "item1", false).Return(nil)
+
+	err := svc.SetMerchSelling(ctx, "cup", false)
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.SetStock ---
+
+func TestMerchService_SetStock_Negative(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	negative := -5
+	err := svc.SetStock(ctx, "pink-hoody", &negative)
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	repo.AssertNotCalled(t, "GetMerchByName")
+}
+
+func TestMerchService_SetStock_Unlimited(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchByName", ctx, "pink-hoody").Return(&model.Merch{Id: "item1", Name: "pink-hoody"}, nil)
+	repo.On("SetMerchStock", ctx, "item1", (*int)(nil)).Return(nil)
+
+	err := svc.SetStock(ctx, "pink-hoody", nil)
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.SetMaxPerUser ---
+
+func TestMerchService_SetMaxPerUser_BadLimit(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	zero := 0
+	err := svc.SetMaxPerUser(ctx, "anniversary-hoody", &zero)
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	repo.AssertNotCalled(t, "GetMerchByName")
+}
+
+func TestMerchService_SetMaxPerUser_Success(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	limit := 1
+	repo.On("GetMerchByName", ctx, "anniversary-hoody").Return(&model.Merch{Id: "item1", Name: "anniversary-hoody"}, nil)
+	repo.On("SetMerchMaxPerUser", ctx, "item1", &limit).Return(nil)
+
+	err := svc.SetMaxPerUser(ctx, "anniversary-hoody", &limit)
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.Restock ---
+
+func TestMerchService_Restock_BadQuantity(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	stock, err := svc.Restock(ctx, "pink-hoody", 0)
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Equal(t, 0, stock)
+}
+
+func TestMerchService_Restock_NotTracked(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchByName", ctx, "pen").Return(&model.Merch{Id: "item2", Name: "pen"}, nil)
+	repo.On("RestockMerch", ctx, "item2", 10).Return(0, cstErrors.StockNotTrackedError)
+
+	_, err := svc.Restock(ctx, "pen", 10)
+	assert.Equal(t, cstErrors.StockNotTrackedError, err)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_Restock_Success(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchByName", ctx, "pink-hoody").Return(&model.Merch{Id: "item1", Name: "pink-hoody"}, nil)
+	repo.On("RestockMerch", ctx, "item1", 10).Return(12, nil)
+
+	stock, err := svc.Restock(ctx, "pink-hoody", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, stock)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.GetLowStock ---
+
+func TestMerchService_GetLowStock_Error(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetLowStockMerch", ctx, 5).Return(nil, errors.New("db error"))
+
+	list, err := svc.GetLowStock(ctx, 5)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "MerchService.GetLowStock")
+	assert.Nil(t, list)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_GetLowStock_Success(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	stock := 2
+	low := []*model.Merch{{Id: "item1", Name: "pink-hoody", Stock: &stock}}
+	repo.On("GetLowStockMerch", ctx, 5).Return(low, nil)
+
+	list, err := svc.GetLowStock(ctx, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, low, list)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.GetCatalog ---
+
+func TestMerchService_GetCatalog_Error(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetCatalog", ctx, "").Return(nil, errors.New("db error"))
+
+	catalog, err := svc.GetCatalog(ctx, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "MerchService.GetCatalog")
+	assert.Nil(t, catalog)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_GetCatalog_Success(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	percentOff := 50
+	endsAt := time.Now().Add(time.Hour)
+	list := []*model.Merch{
+		{Id: "item1", Name: "cup", Price: 20},
+		{Id: "item2", Name: "hoody", Price: 300, Sale: &model.PriceSchedule{EndsAt: endsAt, PercentOff: &percentOff}},
+	}
+	repo.On("GetCatalog", ctx, "").Return(list, nil)
+
+	catalog, err := svc.GetCatalog(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.CatalogItem{
+		{Id: "item1", Name: "cup", Price: 20, EffectivePrice: 20},
+		{Id: "item2", Name: "hoody", Price: 300, EffectivePrice: 150, SaleEndsAt: &endsAt},
+	}, catalog)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_GetCatalog_Variants(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	stock := 4
+	list := []*model.Merch{
+		{Id: "item1", Name: "hoody", Category: "clothes", Price: 300, HasVariants: true},
+		{Id: "item2", Name: "socks", Category: "clothes", Price: 10},
+	}
+	repo.On("GetCatalog", ctx, "clothes").Return(list, nil)
+	repo.On("GetMerchVariants", ctx, []string{"item1"}).Return([]*model.MerchVariant{
+		{Id: "var1", MerchId: "item1", Size: "M", Stock: &stock},
+		{Id: "var2", MerchId: "item1", Size: "L"},
+	}, nil)
+
+	catalog, err := svc.GetCatalog(ctx, "clothes")
+	assert.NoError(t, err)
+	assert.Len(t, catalog, 2)
+	assert.Equal(t, []*model.CatalogVariant{
+		{Id: "var1", Size: "M", Stock: &stock},
+		{Id: "var2", Size: "L"},
+	}, catalog[0].Variants)
+	assert.Nil(t, catalog[1].Variants)
+	assert.Equal(t, "clothes", catalog[1].Category)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.AddVariant ---
+
+func TestMerchService_AddVariant_BadRequest(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	negative := -1
+	variant, err := svc.AddVariant(ctx, "hoody", &model.MerchVariantRequest{Size: " "})
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, variant)
+
+	variant, err = svc.AddVariant(ctx, "hoody", &model.MerchVariantRequest{Size: "XL", Stock: &negative})
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, variant)
+	repo.AssertNotCalled(t, "GetMerchByName", mock.Anything, mock.Anything)
+}
+
+func TestMerchService_AddVariant_AlreadyExists(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchByName", ctx, "hoody").Return(&model.Merch{Id: "item1", Name: "hoody"}, nil)
+	repo.On("CreateMerchVariant", ctx, mock.AnythingOfType("*model.MerchVariant")).Return(nil, cstErrors.VariantAlreadyExistsError)
+
+	variant, err := svc.AddVariant(ctx, "hoody", &model.MerchVariantRequest{Size: "XL"})
+	assert.Equal(t, cstErrors.VariantAlreadyExistsError, err)
+	assert.Nil(t, variant)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_AddVariant_Success(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	stock := 10
+	repo.On("GetMerchByName", ctx, "hoody").Return(&model.Merch{Id: "item1", Name: "hoody"}, nil)
+	created := &model.MerchVariant{Id: "var1", MerchId: "item1", Size: "XL", Color: "black", Stock: &stock}
+	repo.On("CreateMerchVariant", ctx, mock.MatchedBy(func(v *model.MerchVariant) bool {
+		return v.MerchId == "item1" && v.Size == "XL" && v.Color == "black" && v.Stock == &stock
+	})).Return(created, nil)
+
+	variant, err := svc.AddVariant(ctx, "hoody", &model.MerchVariantRequest{Size: " XL", Color: "black ", Stock: &stock})
+	assert.NoError(t, err)
+	assert.Equal(t, created, variant)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.SetDetails ---
+
+func TestMerchService_SetDetails_Success(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchByName", ctx, "cup").Return(&model.Merch{Id: "item1", Name: "cup"}, nil)
+	repo.On("SetMerchDetails", ctx, "item1", "Ceramic cup", "kitchen", "https://example.com/cup.png").Return(nil)
+
+	err := svc.SetDetails(ctx, "cup", &model.MerchDetailsRequest{
+		Description: "Ceramic cup", Category: " kitchen", ImageURL: "https://example.com/cup.png",
+	})
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.CreatePriceSchedule ---
+
+func TestMerchService_CreatePriceSchedule_BadRequest(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	now := time.Now()
+	salePrice, zero, percentOff := 10, 0, 100
+	requests := []*model.PriceScheduleRequest{
+		{StartsAt: now, EndsAt: now, SalePrice: &salePrice},
+		{StartsAt: now, EndsAt: now.Add(time.Hour)},
+		{StartsAt: now, EndsAt: now.Add(time.Hour), SalePrice: &salePrice, PercentOff: &percentOff},
+		{StartsAt: now, EndsAt: now.Add(time.Hour), SalePrice: &zero},
+		{StartsAt: now, EndsAt: now.Add(time.Hour), PercentOff: &percentOff},
+	}
+	for _, req := range requests {
+		schedule, err := svc.CreatePriceSchedule(ctx, "cup", req)
+		assert.Equal(t, cstErrors.BadRequestDataError, err)
+		assert.Nil(t, schedule)
+	}
+	repo.AssertNotCalled(t, "GetMerchByName", mock.Anything, mock.Anything)
+}
+
+func TestMerchService_CreatePriceSchedule_NotFound(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	percentOff := 20
+	now := time.Now()
+	repo.On("GetMerchByName", ctx, "ghost").Return(nil, cstErrors.NotFoundError)
+
+	schedule, err := svc.CreatePriceSchedule(ctx, "ghost", &model.PriceScheduleRequest{
+		StartsAt: now, EndsAt: now.Add(time.Hour), PercentOff: &percentOff,
+	})
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	assert.Nil(t, schedule)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_CreatePriceSchedule_SalePriceNotLower(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	now := time.Now()
+	repo.On("GetMerchByName", ctx, "cup").Return(&model.Merch{Id: "item1", Name: "cup", Price: 20}, nil)
+
+	for _, salePrice := range []int{20, 25} {
+		schedule, err := svc.CreatePriceSchedule(ctx, "cup", &model.PriceScheduleRequest{
+			StartsAt: now, EndsAt: now.Add(time.Hour), SalePrice: &salePrice,
+		})
+		assert.Equal(t, cstErrors.BadRequestDataError, err, "sale price %d", salePrice)
+		assert.Nil(t, schedule)
+	}
+	repo.AssertNotCalled(t, "CreatePriceSchedule", mock.Anything, mock.Anything)
+}
+
+func TestMerchService_CreatePriceSchedule_Success(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	salePrice := 15
+	now := time.Now()
+	repo.On("GetMerchByName", ctx, "cup").Return(&model.Merch{Id: "item1", Name: "cup", Price: 20}, nil)
+	created := &model.PriceSchedule{Id: 1, MerchId: "item1", StartsAt: now, EndsAt: now.Add(time.Hour), SalePrice: &salePrice}
+	repo.On("CreatePriceSchedule", ctx, mock.MatchedBy(func(s *model.PriceSchedule) bool {
+		return s.MerchId == "item1" && s.SalePrice == &salePrice && s.PercentOff == nil
+	})).Return(created, nil)
+
+	schedule, err := svc.CreatePriceSchedule(ctx, "cup", &model.PriceScheduleRequest{
+		StartsAt: now, EndsAt: now.Add(time.Hour), SalePrice: &salePrice,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, created, schedule)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.DeletePriceSchedule ---
+
+func TestMerchService_DeletePriceSchedule_NotFound(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("DeletePriceSchedule", ctx, int64(7)).Return(cstErrors.NotFoundError)
+
+	err := svc.DeletePriceSchedule(ctx, 7)
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for MerchService.CreatePromoCode ---
+
+func TestMerchService_CreatePromoCode_BadRequest(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	zero := 0
+	past := time.Now().Add(-time.Hour)
+	requests := []*model.PromoCodeRequest{
+		{Code: " ", DiscountType: model.PromoDiscountFixed, DiscountValue: 10},
+		{Code: "SPRING", DiscountType: "gift", DiscountValue: 10},
+		{Code: "SPRING", DiscountType: model.PromoDiscountFixed, DiscountValue: 0},
+		{Code: "SPRING", DiscountType: model.PromoDiscountPercent, DiscountValue: 100},
+		{Code: "SPRING", DiscountType: model.PromoDiscountFixed, DiscountValue: 10, MaxUses: &zero},
+		{Code: "SPRING", DiscountType: model.PromoDiscountFixed, DiscountValue: 10, MaxUsesPerUser: &zero},
+		{Code: "SPRING", DiscountType: model.PromoDiscountFixed, DiscountValue: 10, ExpiresAt: &past},
+	}
+	for _, req := range requests {
+		promo, err := svc.CreatePromoCode(ctx, req)
+		assert.Equal(t, cstErrors.BadRequestDataError, err)
+		assert.Nil(t, promo)
+	}
+	repo.AssertNotCalled(t, "CreatePromoCode", mock.Anything, mock.Anything)
+}
+
+func TestMerchService_CreatePromoCode_MerchNotFound(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchByName", ctx, "ghost").Return(nil, cstErrors.NotFoundError)
+
+	promo, err := svc.CreatePromoCode(ctx, &model.PromoCodeRequest{
+		Code: "spring", DiscountType: model.PromoDiscountPercent, DiscountValue: 10, Merch: []string{"ghost"},
+	})
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	assert.Nil(t, promo)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_CreatePromoCode_Success(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	maxUses := 100
+	repo.On("GetMerchByName", ctx, "cup").Return(&model.Merch{Id: "item1", Name: "cup"}, nil)
+	created := &model.PromoCode{Id: 1, Code: "SPRING"}
+	repo.On("CreatePromoCode", ctx, mock.MatchedBy(func(p *model.PromoCode) bool {
+		return p.Code == "SPRING" && p.DiscountValue == 10 && p.MaxUses == &maxUses &&
+			len(p.MerchIds) == 1 && p.MerchIds[0] == "item1"
+	})).Return(created, nil)
+
+	promo, err := svc.CreatePromoCode(ctx, &model.PromoCodeRequest{
+		Code: " spring ", DiscountType: model.PromoDiscountPercent, DiscountValue: 10, MaxUses: &maxUses, Merch: []string{"cup"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, created, promo)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_CreatePromoCode_AlreadyExists(t *testing.T) {
+	repo := new(MockMerchRepository)
+	svc := NewMerchService(repo)
+	ctx := context.Background()
+
+	repo.On("CreatePromoCode", ctx, mock.AnythingOfType("*model.PromoCode")).Return(nil, cstErrors.PromoAlreadyExistsError)
+
+	promo, err := svc.CreatePromoCode(ctx, &model.PromoCodeRequest{
+		Code: "SPRING", DiscountType: model.PromoDiscountFixed, DiscountValue: 10,
+	})
+	assert.Equal(t, cstErrors.PromoAlreadyExistsError, err)
+	assert.Nil(t, promo)
+	repo.AssertExpectations(t)
+}
@@ -4,17 +4,54 @@ import (
 	"context"
 	"fmt"
 	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/metrics"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/utils"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxBulkTransfers    = 1000
+	defaultCancelReason = "order cancelled"
 )
 
 type TransactionRepository interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 	GetMerchById(ctx context.Context, itemId string) (*model.Merch, error)
+	DecrementMerchStock(ctx context.Context, merchId string, quantity int) error
+	GetMerchVariant(ctx context.Context, id string) (*model.MerchVariant, error)
+	DecrementVariantStock(ctx context.Context, id string, quantity int) error
+	IncrementMerchStock(ctx context.Context, merchId string, quantity int) error
+	IncrementVariantStock(ctx context.Context, id string, quantity int) error
+	CountUserPurchases(ctx context.Context, userId, merchId string) (int, error)
+	GetPromoCodeByCode(ctx context.Context, code string) (*model.PromoCode, error)
+	RedeemPromoCode(ctx context.Context, promoId int64) error
+	CountPromoRedemptions(ctx context.Context, promoId int64, userId string) (int, error)
+	LogPromoRedemption(ctx context.Context, promoId int64, userId string, purchaseId int64, discount int) error
+	ReleasePromoRedemption(ctx context.Context, purchaseId int64) error
+	GetCartItems(ctx context.Context, userId string) ([]*model.CartItem, error)
+	ClearCart(ctx context.Context, userId string) error
+	GetUserIdsByLogins(ctx context.Context, logins []string) (map[string]string, error)
 
+	LockUsers(ctx context.Context, userIds []string) error
 	UpdateBalance(ctx context.Context, userId string, diffBalance int) error
-	LogTransferCoin(ctx context.Context, fromUserId, toUserId string, amount int) error
-	LogBuyMerch(ctx context.Context, userId, merchId string, price int) error
-	GetTransactionHistoryReceived(ctx context.Context, userId string) ([]*model.ReceivedCoin, error)
-	GetTransactionHistorySent(ctx context.Context, userId string) ([]*model.SentCoin, error)
+	TransferCoins(ctx context.Context, fromUserId, toUserId string, amount int) error
+	LogTransaction(ctx context.Context, transaction *model.Transaction) error
+	GetTransactionById(ctx context.Context, id int64) (*model.Transaction, error)
+	MarkTransactionReversed(ctx context.Context, id int64) error
+	GetPurchaseById(ctx context.Context, id int64) (*model.Purchase, error)
+	MarkPurchaseReversed(ctx context.Context, id int64) error
+	SetPurchaseStatus(ctx context.Context, id int64, status string) error
+	SetPurchaseOwner(ctx context.Context, id int64, userId string) error
+	LogItemTransfer(ctx context.Context, purchaseId int64, fromUserId, toUserId string) error
+	GetUserPurchases(ctx context.Context, userId string) ([]*model.PurchaseInfo, error)
+	LogBuyMerch(ctx context.Context, buyerId, ownerId, merchId, variantId string, price int) (int64, error)
+	GetTransactionHistoryReceived(ctx context.Context, userId, category string) ([]*model.ReceivedCoin, error)
+	GetTransactionHistorySent(ctx context.Context, userId, category string) ([]*model.SentCoin, error)
 	GetInventory(ctx context.Context, userId string) ([]*model.InfoInventory, error)
 }
 
@@ -28,39 +65,210 @@ func NewTransactionService(repo TransactionRepository) *TransactionService {
 	}
 }
 
-func (t *TransactionService) SendCoin(ctx context.Context, fromUserId, toUserId string, amount int) error {
+// SendCoin transfers coins between users. Coins taken from the sender's allowance lots
+// reach the recipient with the same expiry.
+func (t *TransactionService) SendCoin(ctx context.Context, fromUserId, toUserId string, amount int, message, category string) error {
 	const op = "TransactionService.SendCoin"
 
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if amount <= 0 {
+		return cstErrors.BadRequestDataError
+	}
 	if fromUserId == toUserId {
 		return cstErrors.CantSendCoinYourselfError
 	}
+	// The system account balance is not tracked, coins can't be sent to it
+	if toUserId == model.SystemUserId {
+		return cstErrors.RecipientNotFoundError
+	}
+	if category != "" && !model.IsValidTransferCategory(category) {
+		return cstErrors.BadRequestDataError
+	}
+	if utf8.RuneCountInString(message) > model.MaxTransferMessageLength {
+		return cstErrors.BadRequestDataError
+	}
 
-	var err error
-	err = t.repo.UpdateBalance(ctx, fromUserId, -amount)
+	err := t.repo.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := t.lockUsers(ctx, fromUserId, toUserId)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		err = t.repo.TransferCoins(ctx, fromUserId, toUserId, amount)
+		if err != nil {
+			if cstErrors.IsCustomError(err) {
+				return err
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		err = t.repo.LogTransaction(ctx, &model.Transaction{
+			FromUserId: fromUserId,
+			ToUserId:   toUserId,
+			Amount:     amount,
+			Message:    message,
+			Category:   category,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
 	if err != nil {
-		if cstErrors.IsCustomError(err) {
+		return err
+	}
+	metrics.CoinsTransferred.Add(float64(amount))
+	return nil
+}
+
+// BulkSendCoin transfers coins to many recipients in one database transaction. Coins are taken
+// from fromUserId balance, or issued by the system account when fromUserId is model.SystemUserId.
+func (t *TransactionService) BulkSendCoin(ctx context.Context, fromUserId string, transfers []*model.BulkTransferItem, reason string) (*model.BulkTransferResponse, error) {
+	const op = "TransactionService.BulkSendCoin"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	fromSystem := fromUserId == model.SystemUserId
+	if len(transfers) == 0 || len(transfers) > maxBulkTransfers || (fromSystem && reason == "") {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	total := 0
+	logins := make([]string, 0, len(transfers))
+	seen := make(map[string]struct{}, len(transfers))
+	for _, tr := range transfers {
+		if tr == nil || tr.ToUser == "" || tr.Amount <= 0 {
+			return nil, cstErrors.BadRequestDataError
+		}
+		if _, ok := seen[tr.ToUser]; ok {
+			return nil, cstErrors.BadRequestDataError
+		}
+		seen[tr.ToUser] = struct{}{}
+		logins = append(logins, tr.ToUser)
+		total += tr.Amount
+	}
+
+	userIds, err := t.repo.GetUserIdsByLogins(ctx, logins)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, login := range logins {
+		userId, ok := userIds[login]
+		if !ok || userId == model.SystemUserId {
+			return nil, cstErrors.RecipientNotFoundError
+		}
+		if userId == fromUserId {
+			return nil, cstErrors.CantSendCoinYourselfError
+		}
+	}
+
+	batchId, err := utils.GenerateUUID()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = t.repo.WithinTransaction(ctx, func(ctx context.Context) error {
+		lockIds := make([]string, 0, len(transfers)+1)
+		lockIds = append(lockIds, fromUserId)
+		for _, tr := range transfers {
+			lockIds = append(lockIds, userIds[tr.ToUser])
+		}
+		err := t.lockUsers(ctx, lockIds...)
+		if err != nil {
 			return err
 		}
-		return fmt.Errorf("%s: %w", op, err)
+		for _, tr := range transfers {
+			toUserId := userIds[tr.ToUser]
+			if fromSystem {
+				err = t.repo.UpdateBalance(ctx, toUserId, tr.Amount)
+			} else {
+				err = t.repo.TransferCoins(ctx, fromUserId, toUserId, tr.Amount)
+			}
+			if err != nil {
+				return err
+			}
+			err = t.repo.LogTransaction(ctx, &model.Transaction{
+				FromUserId: fromUserId,
+				ToUserId:   toUserId,
+				Amount:     tr.Amount,
+				Reason:     reason,
+				BatchId:    batchId,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	err = t.repo.UpdateBalance(ctx, toUserId, amount)
+	if !fromSystem {
+		metrics.CoinsTransferred.Add(float64(total))
+	}
+
+	return &model.BulkTransferResponse{
+		BatchId:    batchId,
+		Recipients: len(transfers),
+		Total:      total,
+	}, nil
+}
+
+// BuyItem buys the merch at its current price. variantId is required for merch sold by variants,
+// promoCode is optional and applied on top of a sale.
+func (t *TransactionService) BuyItem(ctx context.Context, userId string, itemId string, variantId string, promoCode string) error {
+	return t.buyItem(ctx, userId, userId, itemId, variantId, promoCode)
+}
+
+// GiftItem buys the merch like BuyItem, but the item goes to the inventory of the recipient
+func (t *TransactionService) GiftItem(ctx context.Context, buyerId, recipient, itemId, variantId, promoCode string) error {
+	const op = "TransactionService.GiftItem"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	ownerId, err := t.resolveRecipient(ctx, recipient)
 	if err != nil {
 		if cstErrors.IsCustomError(err) {
 			return err
 		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if ownerId == buyerId {
+		return cstErrors.CantGiftYourselfError
+	}
+	return t.buyItem(ctx, buyerId, ownerId, itemId, variantId, promoCode)
+}
 
-	err = t.repo.LogTransferCoin(ctx, fromUserId, toUserId, amount)
+// resolveRecipient returns id of the user who receives merch, the system account cannot receive it
+func (t *TransactionService) resolveRecipient(ctx context.Context, login string) (string, error) {
+	if login == "" {
+		return "", cstErrors.BadRequestDataError
+	}
+	ids, err := t.repo.GetUserIdsByLogins(ctx, []string{login})
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return "", err
 	}
-	return nil
+	userId, ok := ids[login]
+	if !ok || userId == model.SystemUserId {
+		return "", cstErrors.RecipientNotFoundError
+	}
+	return userId, nil
 }
 
-func (t *TransactionService) BuyItem(ctx context.Context, userId string, itemId string) error {
+// buyItem charges the buyer and records the item for the owner. Purchase limits
+// and promo code caps apply to the buyer.
+func (t *TransactionService) buyItem(ctx context.Context, userId, ownerId, itemId, variantId, promoCode string) error {
 	const op = "TransactionService.BuyItem"
 
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
 	var err error
 	merch, err := t.repo.GetMerchById(ctx, itemId)
 	if err != nil {
@@ -74,28 +282,548 @@ func (t *TransactionService) BuyItem(ctx context.Context, userId string, itemId
 		return cstErrors.NoSellingMerchError
 	}
 
-	err = t.repo.UpdateBalance(ctx, userId, -merch.Price)
+	variant, err := resolveVariant(ctx, t.repo, merch, variantId)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var promo *model.PromoCode
+	if promoCode != "" {
+		if promo, err = t.getApplicablePromoCode(ctx, promoCode, itemId); err != nil {
+			return err
+		}
+	}
+
+	basePrice := merch.EffectivePrice()
+	price := basePrice
+	if promo != nil {
+		price = promo.Apply(basePrice)
+	}
+	err = t.repo.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := t.decrementStock(ctx, merch, variant, 1); err != nil {
+			if cstErrors.IsCustomError(err) {
+				return err
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Redeeming locks the promo code row, so per-user counting below is safe too
+		if promo != nil {
+			if err := t.repo.RedeemPromoCode(ctx, promo.Id); err != nil {
+				if cstErrors.IsCustomError(err) {
+					return err
+				}
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		err := t.repo.UpdateBalance(ctx, userId, -price)
+		if err != nil {
+			if cstErrors.IsCustomError(err) {
+				return err
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// The balance update above locks the user row, so concurrent purchases
+		// of the same user are serialized and see each other in the count
+		if merch.MaxPerUser != nil {
+			bought, err := t.repo.CountUserPurchases(ctx, userId, itemId)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			if bought >= *merch.MaxPerUser {
+				return cstErrors.PurchaseLimitError
+			}
+		}
+
+		if promo != nil && promo.MaxUsesPerUser != nil {
+			used, err := t.repo.CountPromoRedemptions(ctx, promo.Id, userId)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			if used >= *promo.MaxUsesPerUser {
+				return cstErrors.PromoLimitError
+			}
+		}
+
+		purchaseId, err := t.repo.LogBuyMerch(ctx, userId, ownerId, itemId, variantId, price)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if promo != nil {
+			if err = t.repo.LogPromoRedemption(ctx, promo.Id, userId, purchaseId, basePrice-price); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	metrics.Purchases.WithLabelValues(merch.Name).Inc()
+	return nil
+}
+
+// Checkout buys everything in the user's cart in one transaction: either all lines
+// are purchased and the cart is emptied, or nothing changes
+func (t *TransactionService) Checkout(ctx context.Context, userId string) (*model.CartResponse, error) {
+	const op = "TransactionService.Checkout"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var cart *model.CartResponse
+	err := t.repo.WithinTransaction(ctx, func(ctx context.Context) error {
+		items, err := t.repo.GetCartItems(ctx, userId)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if len(items) == 0 {
+			return cstErrors.EmptyCartError
+		}
+		for _, item := range items {
+			if !item.Merch.IsSelling {
+				return cstErrors.NoSellingMerchError
+			}
+			// Variants may be added to the merch after the line was put into the cart
+			if item.Variant == nil && item.Merch.HasVariants {
+				return cstErrors.VariantRequiredError
+			}
+		}
+		cart = buildCart(items)
+
+		for _, item := range sortedByStockRow(items) {
+			if err = t.decrementStock(ctx, item.Merch, item.Variant, item.Quantity); err != nil {
+				if cstErrors.IsCustomError(err) {
+					return err
+				}
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		if err = t.repo.UpdateBalance(ctx, userId, -cart.Total); err != nil {
+			if cstErrors.IsCustomError(err) {
+				return err
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for i, item := range items {
+			if item.Merch.MaxPerUser != nil {
+				bought, err := t.repo.CountUserPurchases(ctx, userId, item.Merch.Id)
+				if err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+				if bought+item.Quantity > *item.Merch.MaxPerUser {
+					return cstErrors.PurchaseLimitError
+				}
+			}
+			for range item.Quantity {
+				if _, err = t.repo.LogBuyMerch(ctx, userId, userId, item.Merch.Id, cart.Items[i].VariantId, cart.Items[i].Price); err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+			}
+		}
+
+		if err = t.repo.ClearCart(ctx, userId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range cart.Items {
+		metrics.Purchases.WithLabelValues(line.Name).Add(float64(line.Quantity))
+	}
+	return cart, nil
+}
+
+// sortedByStockRow returns the cart lines ordered by merch and variant id. Stock rows are updated
+// in this order, so concurrent checkouts of the same items lock them in the same order and can't deadlock.
+func sortedByStockRow(items []*model.CartItem) []*model.CartItem {
+	variantId := func(item *model.CartItem) string {
+		if item.Variant == nil {
+			return ""
+		}
+		return item.Variant.Id
+	}
+	sorted := make([]*model.CartItem, len(items))
+	copy(sorted, items)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Merch.Id != sorted[j].Merch.Id {
+			return sorted[i].Merch.Id < sorted[j].Merch.Id
+		}
+		return variantId(sorted[i]) < variantId(sorted[j])
+	})
+	return sorted
+}
+
+// decrementStock takes items from the variant stock, or from the merch stock for merch without variants
+func (t *TransactionService) decrementStock(ctx context.Context, merch *model.Merch, variant *model.MerchVariant, quantity int) error {
+	switch {
+	case variant != nil && variant.Stock != nil:
+		return t.repo.DecrementVariantStock(ctx, variant.Id, quantity)
+	case variant == nil && merch.Stock != nil:
+		return t.repo.DecrementMerchStock(ctx, merch.Id, quantity)
+	}
+	return nil
+}
+
+func (t *TransactionService) getApplicablePromoCode(ctx context.Context, code, itemId string) (*model.PromoCode, error) {
+	const op = "TransactionService.getApplicablePromoCode"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	promo, err := t.repo.GetPromoCodeByCode(ctx, strings.ToUpper(code))
+	if err != nil {
+		if err == cstErrors.NotFoundError {
+			return nil, cstErrors.InvalidPromoCodeError
+		}
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if promo.IsExpired(time.Now()) {
+		return nil, cstErrors.InvalidPromoCodeError
+	}
+	if !promo.AppliesTo(itemId) {
+		return nil, cstErrors.PromoNotApplicableError
+	}
+	return promo, nil
+}
+
+func (t *TransactionService) GrantCoins(ctx context.Context, userId string, amount int, reason string) error {
+	const op = "TransactionService.GrantCoins"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := t.adjustBalance(ctx, model.SystemUserId, userId, amount, reason); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (t *TransactionService) DeductCoins(ctx context.Context, userId string, amount int, reason string) error {
+	const op = "TransactionService.DeductCoins"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := t.adjustBalance(ctx, userId, model.SystemUserId, amount, reason); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// lockUsers locks the users whose balances are about to change, sorted by id, so concurrent transfers
+// in opposite directions wait for each other instead of deadlocking. The system account is not locked.
+func (t *TransactionService) lockUsers(ctx context.Context, userIds ...string) error {
+	ids := make([]string, 0, len(userIds))
+	for _, id := range userIds {
+		if id != model.SystemUserId {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Strings(ids)
+	return t.repo.LockUsers(ctx, ids)
+}
+
+// adjustBalance moves coins between a user and the system account. The system account balance is not tracked.
+func (t *TransactionService) adjustBalance(ctx context.Context, fromUserId, toUserId string, amount int, reason string) error {
+	if amount <= 0 || reason == "" || fromUserId == toUserId {
+		return cstErrors.BadRequestDataError
+	}
+
+	userId, diff := toUserId, amount
+	if toUserId == model.SystemUserId {
+		userId, diff = fromUserId, -amount
+	}
+
+	return t.repo.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := t.lockUsers(ctx, userId); err != nil {
+			return err
+		}
+		if err := t.repo.UpdateBalance(ctx, userId, diff); err != nil {
+			return err
+		}
+		return t.repo.LogTransaction(ctx, &model.Transaction{
+			FromUserId: fromUserId,
+			ToUserId:   toUserId,
+			Amount:     amount,
+			Reason:     reason,
+		})
+	})
+}
+
+// ReverseTransaction returns the coins of a transfer, grant or clawback to their origin and
+// logs the compensating entry linked to the original transaction
+func (t *TransactionService) ReverseTransaction(ctx context.Context, transactionId int64, reason string) (*model.Transaction, error) {
+	const op = "TransactionService.ReverseTransaction"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if reason == "" {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	var reversal *model.Transaction
+	err := t.repo.WithinTransaction(ctx, func(ctx context.Context) error {
+		orig, err := t.repo.GetTransactionById(ctx, transactionId)
+		if err != nil {
+			return err
+		}
+		if orig.ReversedAt != nil {
+			return cstErrors.AlreadyReversedError
+		}
+		// Compensating entries and refunds are not reversible, and coins of deleted users can't be restored
+		if orig.ReversalOf != 0 || orig.PurchaseId != 0 || orig.FromUserId == "" || orig.ToUserId == "" {
+			return cstErrors.BadRequestDataError
+		}
+		// Expired coins are gone for good, a reversal would return them without a lot
+		if orig.Kind == model.TransactionKindExpiry {
+			return cstErrors.BadRequestDataError
+		}
+
+		if err = t.lockUsers(ctx, orig.FromUserId, orig.ToUserId); err != nil {
+			return err
+		}
+		if err = t.repo.MarkTransactionReversed(ctx, orig.Id); err != nil {
+			return err
+		}
+		switch {
+		case orig.FromUserId == model.SystemUserId:
+			err = t.repo.UpdateBalance(ctx, orig.ToUserId, -orig.Amount)
+		case orig.ToUserId == model.SystemUserId:
+			err = t.repo.UpdateBalance(ctx, orig.FromUserId, orig.Amount)
+		default:
+			// Coins go back with the expiry they have now
+			err = t.repo.TransferCoins(ctx, orig.ToUserId, orig.FromUserId, orig.Amount)
+		}
+		if err != nil {
+			return err
+		}
+
+		reversal = &model.Transaction{
+			FromUserId: orig.ToUserId,
+			ToUserId:   orig.FromUserId,
+			Amount:     orig.Amount,
+			Reason:     reason,
+			ReversalOf: orig.Id,
+		}
+		return t.repo.LogTransaction(ctx, reversal)
+	})
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return reversal, nil
+}
+
+// ReversePurchase refunds the price of a purchase from the system account and removes the item from the inventory
+func (t *TransactionService) ReversePurchase(ctx context.Context, purchaseId int64, reason string) (*model.Transaction, error) {
+	const op = "TransactionService.ReversePurchase"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if reason == "" {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	var refund *model.Transaction
+	err := t.repo.WithinTransaction(ctx, func(ctx context.Context) error {
+		purchase, err := t.repo.GetPurchaseById(ctx, purchaseId)
+		if err != nil {
+			return err
+		}
+		if purchase.ReversedAt != nil {
+			return cstErrors.AlreadyReversedError
+		}
+
+		refund, err = t.refundPurchase(ctx, purchase, reason)
+		return err
+	})
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return refund, nil
+}
+
+// ChangeOrderStatus moves the order along its lifecycle. Cancelling the order refunds its price,
+// reason is recorded with the refund and defaults to defaultCancelReason.
+func (t *TransactionService) ChangeOrderStatus(ctx context.Context, purchaseId int64, status, reason string) error {
+	const op = "TransactionService.ChangeOrderStatus"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if !model.IsValidOrderStatus(status) {
+		return cstErrors.BadRequestDataError
+	}
+	if reason == "" {
+		reason = defaultCancelReason
+	}
+
+	err := t.repo.WithinTransaction(ctx, func(ctx context.Context) error {
+		purchase, err := t.repo.GetPurchaseById(ctx, purchaseId)
+		if err != nil {
+			return err
+		}
+		if !model.CanChangeOrderStatus(purchase.Status, status) {
+			return cstErrors.OrderStatusError
+		}
+
+		if status == model.OrderStatusCancelled {
+			_, err = t.refundPurchase(ctx, purchase, reason)
+			return err
+		}
+		return t.repo.SetPurchaseStatus(ctx, purchase.Id, status)
+	})
 	if err != nil {
 		if cstErrors.IsCustomError(err) {
 			return err
 		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	err = t.repo.LogBuyMerch(ctx, userId, itemId, merch.Price)
+	return nil
+}
+
+// TransferItem hands the owned item to another user. Only the current owner can transfer it,
+// cancelled purchases cannot be transferred.
+func (t *TransactionService) TransferItem(ctx context.Context, userId string, purchaseId int64, recipient string) error {
+	const op = "TransactionService.TransferItem"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	toUserId, err := t.resolveRecipient(ctx, recipient)
 	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if toUserId == userId {
+		return cstErrors.CantTransferYourselfError
+	}
+
+	err = t.repo.WithinTransaction(ctx, func(ctx context.Context) error {
+		purchase, err := t.repo.GetPurchaseById(ctx, purchaseId)
+		if err != nil {
+			return err
+		}
+		if purchase.UserId != userId {
+			return cstErrors.NotFoundError
+		}
+		if purchase.ReversedAt != nil || purchase.Status == model.OrderStatusCancelled {
+			return cstErrors.ItemNotTransferableError
+		}
+
+		if err = t.repo.SetPurchaseOwner(ctx, purchase.Id, toUserId); err != nil {
+			return err
+		}
+		return t.repo.LogItemTransfer(ctx, purchase.Id, userId, toUserId)
+	})
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
-func (t *TransactionService) GetTransactionsHistory(ctx context.Context, userId string) (*model.CoinHistory, error) {
+// refundPurchase cancels the purchase, returns the item to the stock, the promo code use and the price
+// to the buyer from the system account, for gifts the coins go to the buyer, not to the recipient
+func (t *TransactionService) refundPurchase(ctx context.Context, purchase *model.Purchase, reason string) (*model.Transaction, error) {
+	if err := t.repo.MarkPurchaseReversed(ctx, purchase.Id); err != nil {
+		return nil, err
+	}
+	if err := t.repo.UpdateBalance(ctx, purchase.BuyerId, purchase.Price); err != nil {
+		return nil, err
+	}
+	if err := t.restoreStock(ctx, purchase); err != nil {
+		return nil, err
+	}
+	if err := t.repo.ReleasePromoRedemption(ctx, purchase.Id); err != nil {
+		return nil, err
+	}
+
+	refund := &model.Transaction{
+		FromUserId: model.SystemUserId,
+		ToUserId:   purchase.BuyerId,
+		Amount:     purchase.Price,
+		Reason:     reason,
+		PurchaseId: purchase.Id,
+	}
+	if err := t.repo.LogTransaction(ctx, refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// restoreStock returns the refunded item to the variant stock, or to the merch stock for purchases without variant.
+// Untracked stock is left untouched by the repository.
+func (t *TransactionService) restoreStock(ctx context.Context, purchase *model.Purchase) error {
+	if purchase.VariantId != "" {
+		return t.repo.IncrementVariantStock(ctx, purchase.VariantId, 1)
+	}
+	return t.repo.IncrementMerchStock(ctx, purchase.MerchId, 1)
+}
+
+func (t *TransactionService) GetPurchases(ctx context.Context, userId string) ([]*model.PurchaseInfo, error) {
+	const op = "TransactionService.GetPurchases"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	purchases, err := t.repo.GetUserPurchases(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return purchases, nil
+}
+
+// GetTransactionsHistory returns coins received and sent by the user, limited to category if it is not empty
+func (t *TransactionService) GetTransactionsHistory(ctx context.Context, userId, category string) (*model.CoinHistory, error) {
 	const op = "TransactionService.GetTransactionsHistory"
 
-	received, err := t.repo.GetTransactionHistoryReceived(ctx, userId)
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if category != "" && !model.IsValidTransferCategory(category) {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	received, err := t.repo.GetTransactionHistoryReceived(ctx, userId, category)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	sent, err := t.repo.GetTransactionHistorySent(ctx, userId)
+	sent, err := t.repo.GetTransactionHistorySent(ctx, userId, category)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -109,6 +837,9 @@ func (t *TransactionService) GetTransactionsHistory(ctx context.Context, userId
 func (t *TransactionService) GetInventory(ctx context.Context, userId string) ([]*model.InfoInventory, error) {
 	const op = "TransactionService.GetInventory"
 
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
 	inventory, err := t.repo.GetInventory(ctx, userId)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -16,6 +18,11 @@ type MockTransactionRepository struct {
 	mock.Mock
 }
 
+// WithinTransaction runs fn directly so expectations are matched against the caller's ctx
+func (m *MockTransactionRepository) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
 func (m *MockTransactionRepository) GetMerchById(ctx context.Context, itemId string) (*model.Merch, error) {
 	args := m.Called(ctx, itemId)
 	if merch := args.Get(0); merch != nil {
@@ -24,31 +31,172 @@ func (m *MockTransactionRepository) GetMerchById(ctx context.Context, itemId str
 	return nil, args.Error(1)
 }
 
+func (m *MockTransactionRepository) DecrementMerchStock(ctx context.Context, merchId string, quantity int) error {
+	args := m.Called(ctx, merchId, quantity)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) CountUserPurchases(ctx context.Context, userId, merchId string) (int, error) {
+	args := m.Called(ctx, userId, merchId)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockTransactionRepository) GetMerchVariant(ctx context.Context, id string) (*model.MerchVariant, error) {
+	args := m.Called(ctx, id)
+	if variant := args.Get(0); variant != nil {
+		return variant.(*model.MerchVariant), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransactionRepository) DecrementVariantStock(ctx context.Context, id string, quantity int) error {
+	args := m.Called(ctx, id, quantity)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) ReleasePromoRedemption(ctx context.Context, purchaseId int64) error {
+	args := m.Called(ctx, purchaseId)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) IncrementMerchStock(ctx context.Context, merchId string, quantity int) error {
+	args := m.Called(ctx, merchId, quantity)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) IncrementVariantStock(ctx context.Context, id string, quantity int) error {
+	args := m.Called(ctx, id, quantity)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) GetPromoCodeByCode(ctx context.Context, code string) (*model.PromoCode, error) {
+	args := m.Called(ctx, code)
+	if promo := args.Get(0); promo != nil {
+		return promo.(*model.PromoCode), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransactionRepository) RedeemPromoCode(ctx context.Context, promoId int64) error {
+	args := m.Called(ctx, promoId)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) CountPromoRedemptions(ctx context.Context, promoId int64, userId string) (int, error) {
+	args := m.Called(ctx, promoId, userId)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockTransactionRepository) SetPurchaseStatus(ctx context.Context, id int64, status string) error {
+	args := m.Called(ctx, id, status)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) SetPurchaseOwner(ctx context.Context, id int64, userId string) error {
+	args := m.Called(ctx, id, userId)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) LogItemTransfer(ctx context.Context, purchaseId int64, fromUserId, toUserId string) error {
+	args := m.Called(ctx, purchaseId, fromUserId, toUserId)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) GetUserPurchases(ctx context.Context, userId string) ([]*model.PurchaseInfo, error) {
+	args := m.Called(ctx, userId)
+	if list := args.Get(0); list != nil {
+		return list.([]*model.PurchaseInfo), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransactionRepository) GetCartItems(ctx context.Context, userId string) ([]*model.CartItem, error) {
+	args := m.Called(ctx, userId)
+	if items := args.Get(0); items != nil {
+		return items.([]*model.CartItem), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransactionRepository) ClearCart(ctx context.Context, userId string) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) LogPromoRedemption(ctx context.Context, promoId int64, userId string, purchaseId int64, discount int) error {
+	args := m.Called(ctx, promoId, userId, purchaseId, discount)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) GetUserIdsByLogins(ctx context.Context, logins []string) (map[string]string, error) {
+	args := m.Called(ctx, logins)
+	if ids := args.Get(0); ids != nil {
+		return ids.(map[string]string), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransactionRepository) TransferCoins(ctx context.Context, fromUserId, toUserId string, amount int) error {
+	args := m.Called(ctx, fromUserId, toUserId, amount)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) LockUsers(ctx context.Context, userIds []string) error {
+	args := m.Called(ctx, userIds)
+	return args.Error(0)
+}
+
 func (m *MockTransactionRepository) UpdateBalance(ctx context.Context, userId string, diffBalance int) error {
 	args := m.Called(ctx, userId, diffBalance)
 	return args.Error(0)
 }
 
-func (m *MockTransactionRepository) LogTransferCoin(ctx context.Context, fromUserId, toUserId string, amount int) error {
-	args := m.Called(ctx, fromUserId, toUserId, amount)
+func (m *MockTransactionRepository) LogTransaction(ctx context.Context, transaction *model.Transaction) error {
+	args := m.Called(ctx, transaction)
 	return args.Error(0)
 }
 
-func (m *MockTransactionRepository) LogBuyMerch(ctx context.Context, userId, merchId string, price int) error {
-	args := m.Called(ctx, userId, merchId, price)
+func (m *MockTransactionRepository) GetTransactionById(ctx context.Context, id int64) (*model.Transaction, error) {
+	args := m.Called(ctx, id)
+	if tr := args.Get(0); tr != nil {
+		return tr.(*model.Transaction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransactionRepository) MarkTransactionReversed(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
-func (m *MockTransactionRepository) GetTransactionHistoryReceived(ctx context.Context, userId string) ([]*model.ReceivedCoin, error) {
-	args := m.Called(ctx, userId)
+func (m *MockTransactionRepository) GetPurchaseById(ctx context.Context, id int64) (*model.Purchase, error) {
+	args := m.Called(ctx, id)
+	if p := args.Get(0); p != nil {
+		return p.(*model.Purchase), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransactionRepository) MarkPurchaseReversed(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) LogBuyMerch(ctx context.Context, buyerId, ownerId, merchId, variantId string, price int) (int64, error) {
+	args := m.Called(ctx, buyerId, ownerId, merchId, variantId, price)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockTransactionRepository) GetTransactionHistoryReceived(ctx context.Context, userId, category string) ([]*model.ReceivedCoin, error) {
+	args := m.Called(ctx, userId, category)
 	if rec := args.Get(0); rec != nil {
 		return rec.([]*model.ReceivedCoin), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
-func (m *MockTransactionRepository) GetTransactionHistorySent(ctx context.Context, userId string) ([]*model.SentCoin, error) {
-	args := m.Called(ctx, userId)
+func (m *MockTransactionRepository) GetTransactionHistorySent(ctx context.Context, userId, category string) ([]*model.SentCoin, error) {
+	args := m.Called(ctx, userId, category)
 	if sent := args.Get(0); sent != nil {
 		return sent.([]*model.SentCoin), args.Error(1)
 	}
@@ -70,65 +218,61 @@ func TestTransactionService_SendCoin_SameUser(t *testing.T) {
 	ts := NewTransactionService(mockRepo)
 	ctx := context.Background()
 
-	err := ts.SendCoin(ctx, "user1", "user1", 100)
+	err := ts.SendCoin(ctx, "user1", "user1", 100, "", "")
 	assert.Error(t, err)
 	assert.Equal(t, cstErrors.CantSendCoinYourselfError, err)
 }
 
-func TestTransactionService_SendCoin_UpdateBalanceFromError(t *testing.T) {
+func TestTransactionService_SendCoin_BadAmount(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
 	ts := NewTransactionService(mockRepo)
 	ctx := context.Background()
 
-	normalErr := errors.New("update error")
-	mockRepo.On("UpdateBalance", ctx, "user1", -100).Return(normalErr)
-
-	err := ts.SendCoin(ctx, "user1", "user2", 100)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "update error")
-	mockRepo.AssertExpectations(t)
+	for _, amount := range []int{0, -100} {
+		err := ts.SendCoin(ctx, "user1", "user2", amount, "", "")
+		assert.Equal(t, cstErrors.BadRequestDataError, err, "amount %d", amount)
+	}
+	mockRepo.AssertNotCalled(t, "TransferCoins")
+	mockRepo.AssertNotCalled(t, "LogTransaction")
 }
 
-func TestTransactionService_SendCoin_UpdateBalanceFromCustomError(t *testing.T) {
+func TestTransactionService_SendCoin_SystemRecipient(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
 	ts := NewTransactionService(mockRepo)
 	ctx := context.Background()
 
-	customErr := cstErrors.InternalError
-	mockRepo.On("UpdateBalance", ctx, "user1", -100).Return(customErr)
-
-	err := ts.SendCoin(ctx, "user1", "user2", 100)
-	assert.Error(t, err)
-	assert.Equal(t, customErr, err)
-	mockRepo.AssertExpectations(t)
+	err := ts.SendCoin(ctx, "user1", model.SystemUserId, 100, "", "")
+	assert.Equal(t, cstErrors.RecipientNotFoundError, err)
+	mockRepo.AssertNotCalled(t, "LockUsers")
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
 }
 
-func TestTransactionService_SendCoin_UpdateBalanceToCustomError(t *testing.T) {
+func TestTransactionService_SendCoin_TransferError(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
 	ts := NewTransactionService(mockRepo)
 	ctx := context.Background()
 
-	mockRepo.On("UpdateBalance", ctx, "user1", -100).Return(nil)
-	customErr := cstErrors.InternalError
-	mockRepo.On("UpdateBalance", ctx, "user2", 100).Return(customErr)
+	normalErr := errors.New("update error")
+	mockRepo.On("LockUsers", ctx, []string{"user1", "user2"}).Return(nil)
+	mockRepo.On("TransferCoins", ctx, "user1", "user2", 100).Return(normalErr)
 
-	err := ts.SendCoin(ctx, "user1", "user2", 100)
+	err := ts.SendCoin(ctx, "user1", "user2", 100, "", "")
 	assert.Error(t, err)
-	assert.Equal(t, customErr, err)
+	assert.Contains(t, err.Error(), "update error")
 	mockRepo.AssertExpectations(t)
 }
 
-func TestTransactionService_SendCoin_UpdateBalanceToError(t *testing.T) {
+func TestTransactionService_SendCoin_TransferCustomError(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
 	ts := NewTransactionService(mockRepo)
 	ctx := context.Background()
 
-	mockRepo.On("UpdateBalance", ctx, "user1", -100).Return(nil)
-	mockRepo.On("UpdateBalance", ctx, "user2", 100).Return(errors.New("update error to"))
+	mockRepo.On("LockUsers", ctx, []string{"user1", "user2"}).Return(nil)
+	mockRepo.On("TransferCoins", ctx, "user1", "user2", 100).Return(cstErrors.NoCoinError)
 
-	err := ts.SendCoin(ctx, "user1", "user2", 100)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "update error to")
+	err := ts.SendCoin(ctx, "user1", "user2", 100, "", "")
+	assert.Equal(t, cstErrors.NoCoinError, err)
+	mockRepo.AssertNotCalled(t, "LogTransaction")
 	mockRepo.AssertExpectations(t)
 }
 
@@ -137,12 +281,12 @@ func TestTransactionService_SendCoin_LogTransferError(t *testing.T) {
 	ts := NewTransactionService(mockRepo)
 	ctx := context.Background()
 
-	mockRepo.On("UpdateBalance", ctx, "user1", -100).Return(nil)
-	mockRepo.On("UpdateBalance", ctx, "user2", 100).Return(nil)
+	mockRepo.On("LockUsers", ctx, []string{"user1", "user2"}).Return(nil)
+	mockRepo.On("TransferCoins", ctx, "user1", "user2", 100).Return(nil)
 	normalErr := errors.New("log transfer error")
-	mockRepo.On("LogTransferCoin", ctx, "user1", "user2", 100).Return(normalErr)
+	mockRepo.On("LogTransaction", ctx, mock.AnythingOfType("*model.Transaction")).Return(normalErr)
 
-	err := ts.SendCoin(ctx, "user1", "user2", 100)
+	err := ts.SendCoin(ctx, "user1", "user2", 100, "", "")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "log transfer error")
 	mockRepo.AssertExpectations(t)
@@ -158,14 +302,178 @@ func TestTransactionService_SendCoin_Success(t *testing.T) {
 	amount := 50
 
 	// Настраиваем мок репозитория
-	mockRepo.On("UpdateBalance", ctx, fromUserID, -amount).Return(nil)
-	mockRepo.On("UpdateBalance", ctx, toUserID, amount).Return(nil)
-	mockRepo.On("LogTransferCoin", ctx, fromUserID, toUserID, amount).Return(nil)
+	mockRepo.On("LockUsers", ctx, []string{"user1", "user2"}).Return(nil)
+	mockRepo.On("TransferCoins", ctx, fromUserID, toUserID, amount).Return(nil)
+	mockRepo.On("LogTransaction", ctx, &model.Transaction{
+		FromUserId: fromUserID,
+		ToUserId:   toUserID,
+		Amount:     amount,
+		Message:    "thanks for the code review",
+		Category:   model.TransferCategoryKudos,
+	}).Return(nil)
+
+	err := ts.SendCoin(ctx, fromUserID, toUserID, amount, "thanks for the code review", model.TransferCategoryKudos)
+
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_SendCoin_LocksUsersInFixedOrder(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	var calls []string
+	record := func(call string) func(mock.Arguments) {
+		return func(mock.Arguments) { calls = append(calls, call) }
+	}
+	// Transfers in both directions lock the same users in the same order before any balance changes
+	mockRepo.On("LockUsers", ctx, []string{"user1", "user2"}).Run(record("lock")).Return(nil)
+	mockRepo.On("TransferCoins", ctx, "user2", "user1", 10).Run(record("transfer")).Return(nil)
+	mockRepo.On("LogTransaction", ctx, mock.AnythingOfType("*model.Transaction")).Return(nil)
+
+	err := ts.SendCoin(ctx, "user2", "user1", 10, "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lock", "transfer"}, calls)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_SendCoin_BadNote(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	err := ts.SendCoin(ctx, "user1", "user2", 100, "", "bribe")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+
+	err = ts.SendCoin(ctx, "user1", "user2", 100, strings.Repeat("a", model.MaxTransferMessageLength+1), "")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+}
+
+// --- Tests for TransactionService.BulkSendCoin ---
 
-	err := ts.SendCoin(ctx, fromUserID, toUserID, amount)
+func TestTransactionService_BulkSendCoin_BadRequest(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	cases := [][]*model.BulkTransferItem{
+		nil,
+		{{ToUser: "bob", Amount: 0}},
+		{{ToUser: "", Amount: 10}},
+		{{ToUser: "bob", Amount: 10}, {ToUser: "bob", Amount: 20}},
+	}
+	for _, transfers := range cases {
+		resp, err := ts.BulkSendCoin(ctx, "user1", transfers, "")
+		assert.Equal(t, cstErrors.BadRequestDataError, err)
+		assert.Nil(t, resp)
+	}
+
+	// Coins issued by the system account require a reason
+	resp, err := ts.BulkSendCoin(ctx, model.SystemUserId, []*model.BulkTransferItem{{ToUser: "bob", Amount: 10}}, "")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, resp)
+	mockRepo.AssertNotCalled(t, "GetUserIdsByLogins")
+}
+
+func TestTransactionService_BulkSendCoin_RecipientNotFound(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	transfers := []*model.BulkTransferItem{{ToUser: "bob", Amount: 10}, {ToUser: "ghost", Amount: 20}}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"bob", "ghost"}).Return(map[string]string{"bob": "user2"}, nil)
+
+	resp, err := ts.BulkSendCoin(ctx, "user1", transfers, "")
+	assert.Equal(t, cstErrors.RecipientNotFoundError, err)
+	assert.Nil(t, resp)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BulkSendCoin_Yourself(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	transfers := []*model.BulkTransferItem{{ToUser: "alice", Amount: 10}}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"alice"}).Return(map[string]string{"alice": "user1"}, nil)
+
+	resp, err := ts.BulkSendCoin(ctx, "user1", transfers, "")
+	assert.Equal(t, cstErrors.CantSendCoinYourselfError, err)
+	assert.Nil(t, resp)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BulkSendCoin_NoCoin(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	transfers := []*model.BulkTransferItem{{ToUser: "bob", Amount: 600}, {ToUser: "carol", Amount: 600}}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"bob", "carol"}).
+		Return(map[string]string{"bob": "user2", "carol": "user3"}, nil)
+	mockRepo.On("LockUsers", ctx, []string{"user1", "user2", "user3"}).Return(nil)
+	mockRepo.On("TransferCoins", ctx, "user1", "user2", 600).Return(nil)
+	mockRepo.On("LogTransaction", ctx, mock.AnythingOfType("*model.Transaction")).Return(nil).Once()
+	mockRepo.On("TransferCoins", ctx, "user1", "user3", 600).Return(cstErrors.NoCoinError)
+
+	resp, err := ts.BulkSendCoin(ctx, "user1", transfers, "")
+	assert.Equal(t, cstErrors.NoCoinError, err)
+	assert.Nil(t, resp)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+	mockRepo.AssertExpectations(t)
+}
 
+func TestTransactionService_BulkSendCoin_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	transfers := []*model.BulkTransferItem{{ToUser: "bob", Amount: 10}, {ToUser: "carol", Amount: 20}}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"bob", "carol"}).
+		Return(map[string]string{"bob": "user2", "carol": "user3"}, nil)
+	mockRepo.On("LockUsers", ctx, []string{"user1", "user2", "user3"}).Return(nil)
+	mockRepo.On("TransferCoins", ctx, "user1", "user2", 10).Return(nil)
+	mockRepo.On("TransferCoins", ctx, "user1", "user3", 20).Return(nil)
+
+	var batchIds []string
+	mockRepo.On("LogTransaction", ctx, mock.AnythingOfType("*model.Transaction")).
+		Run(func(args mock.Arguments) {
+			tr := args.Get(1).(*model.Transaction)
+			assert.Equal(t, "user1", tr.FromUserId)
+			assert.Equal(t, "thanks", tr.Reason)
+			batchIds = append(batchIds, tr.BatchId)
+		}).
+		Return(nil)
+
+	resp, err := ts.BulkSendCoin(ctx, "user1", transfers, "thanks")
 	assert.NoError(t, err)
+	assert.Equal(t, 2, resp.Recipients)
+	assert.Equal(t, 30, resp.Total)
+	assert.NotEmpty(t, resp.BatchId)
+	assert.Equal(t, []string{resp.BatchId, resp.BatchId}, batchIds)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BulkSendCoin_FromSystem(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	transfers := []*model.BulkTransferItem{{ToUser: "bob", Amount: 100}}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"bob"}).Return(map[string]string{"bob": "user2"}, nil)
+	mockRepo.On("LockUsers", ctx, []string{"user2"}).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user2", 100).Return(nil)
+	mockRepo.On("LogTransaction", ctx, mock.AnythingOfType("*model.Transaction")).Return(nil)
 
+	resp, err := ts.BulkSendCoin(ctx, model.SystemUserId, transfers, "quarterly award")
+	assert.NoError(t, err)
+	assert.Equal(t, 100, resp.Total)
+	mockRepo.AssertNumberOfCalls(t, "UpdateBalance", 1)
+	mockRepo.AssertNotCalled(t, "TransferCoins")
 	mockRepo.AssertExpectations(t)
 }
 
@@ -179,7 +487,7 @@ func TestTransactionService_BuyItem_GetMerchCustomError(t *testing.T) {
 	customErr := cstErrors.InternalError
 	mockRepo.On("GetMerchById", ctx, "item1").Return(nil, customErr)
 
-	err := ts.BuyItem(ctx, "user1", "item1")
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
 	assert.Error(t, err)
 	assert.Equal(t, customErr, err)
 	mockRepo.AssertExpectations(t)
@@ -192,7 +500,7 @@ func TestTransactionService_BuyItem_GetMerchError(t *testing.T) {
 
 	mockRepo.On("GetMerchById", ctx, "item1").Return(nil, errors.New("merch error"))
 
-	err := ts.BuyItem(ctx, "user1", "item1")
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "merch error")
 	mockRepo.AssertExpectations(t)
@@ -206,7 +514,7 @@ func TestTransactionService_BuyItem_MerchNotSelling(t *testing.T) {
 	merch := &model.Merch{Id: "item1", Price: 500, IsSelling: false}
 	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
 
-	err := ts.BuyItem(ctx, "user1", "item1")
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
 	assert.Error(t, err)
 	assert.Equal(t, cstErrors.NoSellingMerchError, err)
 	mockRepo.AssertExpectations(t)
@@ -222,7 +530,7 @@ func TestTransactionService_BuyItem_UpdateBalanceCustomError(t *testing.T) {
 	customErr := cstErrors.InternalError
 	mockRepo.On("UpdateBalance", ctx, "user1", -500).Return(customErr)
 
-	err := ts.BuyItem(ctx, "user1", "item1")
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
 	assert.Error(t, err)
 	assert.Equal(t, customErr, err)
 	mockRepo.AssertExpectations(t)
@@ -237,7 +545,7 @@ func TestTransactionService_BuyItem_UpdateBalanceError(t *testing.T) {
 	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
 	mockRepo.On("UpdateBalance", ctx, "user1", -500).Return(errors.New("balance update error"))
 
-	err := ts.BuyItem(ctx, "user1", "item1")
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "balance update error")
 	mockRepo.AssertExpectations(t)
@@ -252,9 +560,9 @@ func TestTransactionService_BuyItem_LogBuyMerchError(t *testing.T) {
 	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
 	mockRepo.On("UpdateBalance", ctx, "user1", -500).Return(nil)
 	normalErr := errors.New("log buy error")
-	mockRepo.On("LogBuyMerch", ctx, "user1", "item1", 500).Return(normalErr)
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user1", "item1", "", 500).Return(int64(0), normalErr)
 
-	err := ts.BuyItem(ctx, "user1", "item1")
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "log buy error")
 	mockRepo.AssertExpectations(t)
@@ -273,59 +581,1152 @@ func TestTransactionService_BuyItem_Success(t *testing.T) {
 
 	mockRepo.On("GetMerchById", ctx, itemID).Return(merch, nil)
 	mockRepo.On("UpdateBalance", ctx, userID, -price).Return(nil)
-	mockRepo.On("LogBuyMerch", ctx, userID, itemID, price).Return(nil)
+	mockRepo.On("LogBuyMerch", ctx, userID, userID, itemID, "", price).Return(int64(1), nil)
 
-	err := ts.BuyItem(ctx, userID, itemID)
+	err := ts.BuyItem(ctx, userID, itemID, "", "")
 
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 }
 
-// --- Tests for TransactionService.GetTransactionsHistory ---
+func TestTransactionService_BuyItem_OutOfStock(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
 
-func TestTransactionService_GetTransactionsHistory_ReceivedError(t *testing.T) {
+	stock := 0
+	merch := &model.Merch{Id: "item1", Price: 500, IsSelling: true, Stock: &stock}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("DecrementMerchStock", ctx, "item1", 1).Return(cstErrors.OutOfStockError)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
+	assert.Equal(t, cstErrors.OutOfStockError, err)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BuyItem_LimitedStock(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
 	ts := NewTransactionService(mockRepo)
 	ctx := context.Background()
 
-	mockRepo.On("GetTransactionHistoryReceived", ctx, "user1").Return([]*model.ReceivedCoin(nil), errors.New("received error"))
+	stock := 3
+	merch := &model.Merch{Id: "item1", Price: 500, IsSelling: true, Stock: &stock}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("DecrementMerchStock", ctx, "item1", 1).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -500).Return(nil)
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user1", "item1", "", 500).Return(int64(1), nil)
 
-	history, err := ts.GetTransactionsHistory(ctx, "user1")
-	assert.Error(t, err)
-	assert.Nil(t, history)
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
+	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 }
 
-func TestTransactionService_GetTransactionsHistory_SentError(t *testing.T) {
+func TestTransactionService_BuyItem_PurchaseLimitReached(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
 	ts := NewTransactionService(mockRepo)
 	ctx := context.Background()
 
-	received := []*model.ReceivedCoin{{Amount: 50}} // имеются полученные транзакции
-	mockRepo.On("GetTransactionHistoryReceived", ctx, "user1").Return(received, nil)
-	mockRepo.On("GetTransactionHistorySent", ctx, "user1").Return([]*model.SentCoin(nil), errors.New("sent error"))
+	limit := 1
+	merch := &model.Merch{Id: "item1", Price: 300, IsSelling: true, MaxPerUser: &limit}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -300).Return(nil)
+	mockRepo.On("CountUserPurchases", ctx, "user1", "item1").Return(1, nil)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
+	assert.Equal(t, cstErrors.PurchaseLimitError, err)
+	mockRepo.AssertNotCalled(t, "LogBuyMerch")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BuyItem_CountPurchasesError(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	limit := 1
+	merch := &model.Merch{Id: "item1", Price: 300, IsSelling: true, MaxPerUser: &limit}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -300).Return(nil)
+	mockRepo.On("CountUserPurchases", ctx, "user1", "item1").Return(0, errors.New("count error"))
 
-	history, err := ts.GetTransactionsHistory(ctx, "user1")
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
 	assert.Error(t, err)
-	assert.Nil(t, history)
+	assert.Contains(t, err.Error(), "count error")
 	mockRepo.AssertExpectations(t)
 }
 
-func TestTransactionService_GetTransactionsHistory_Success(t *testing.T) {
+func TestTransactionService_BuyItem_UnderPurchaseLimit(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
 	ts := NewTransactionService(mockRepo)
 	ctx := context.Background()
 
-	received := []*model.ReceivedCoin{{Amount: 50}}
-	sent := []*model.SentCoin{{Amount: 30}}
+	limit := 2
+	merch := &model.Merch{Id: "item1", Price: 300, IsSelling: true, MaxPerUser: &limit}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -300).Return(nil)
+	mockRepo.On("CountUserPurchases", ctx, "user1", "item1").Return(1, nil)
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user1", "item1", "", 300).Return(int64(1), nil)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BuyItem_SalePrice(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
 
-	mockRepo.On("GetTransactionHistoryReceived", ctx, "user1").Return(received, nil)
-	mockRepo.On("GetTransactionHistorySent", ctx, "user1").Return(sent, nil)
+	salePrice := 350
+	merch := &model.Merch{Id: "item1", Price: 500, IsSelling: true, Sale: &model.PriceSchedule{SalePrice: &salePrice}}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -350).Return(nil)
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user1", "item1", "", 350).Return(int64(1), nil)
 
-	history, err := ts.GetTransactionsHistory(ctx, "user1")
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
 	assert.NoError(t, err)
-	assert.Equal(t, received, history.Received)
-	assert.Equal(t, sent, history.Sent)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BuyItem_PercentOff(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	percentOff := 25
+	merch := &model.Merch{Id: "item1", Price: 10, IsSelling: true, Sale: &model.PriceSchedule{PercentOff: &percentOff}}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -7).Return(nil)
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user1", "item1", "", 7).Return(int64(1), nil)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BuyItem_VariantRequired(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	merch := &model.Merch{Id: "item1", Price: 300, IsSelling: true, HasVariants: true}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "")
+	assert.Equal(t, cstErrors.VariantRequiredError, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+}
+
+func TestTransactionService_BuyItem_VariantOfOtherMerch(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	merch := &model.Merch{Id: "item1", Price: 300, IsSelling: true, HasVariants: true}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("GetMerchVariant", ctx, "var9").Return(&model.MerchVariant{Id: "var9", MerchId: "item2"}, nil)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "var9", "")
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BuyItem_VariantOutOfStock(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	stock := 0
+	merch := &model.Merch{Id: "item1", Price: 300, IsSelling: true, HasVariants: true}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("GetMerchVariant", ctx, "var1").Return(&model.MerchVariant{Id: "var1", MerchId: "item1", Size: "XL", Stock: &stock}, nil)
+	mockRepo.On("DecrementVariantStock", ctx, "var1", 1).Return(cstErrors.OutOfStockError)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "var1", "")
+	assert.Equal(t, cstErrors.OutOfStockError, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+}
+
+func TestTransactionService_BuyItem_Variant(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	stock := 3
+	merch := &model.Merch{Id: "item1", Price: 300, IsSelling: true, HasVariants: true}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("GetMerchVariant", ctx, "var1").Return(&model.MerchVariant{Id: "var1", MerchId: "item1", Size: "XL", Stock: &stock}, nil)
+	mockRepo.On("DecrementVariantStock", ctx, "var1", 1).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -300).Return(nil)
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user1", "item1", "var1", 300).Return(int64(1), nil)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "var1", "")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "DecrementMerchStock")
+}
+
+func TestTransactionService_BuyItem_PromoNotFound(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	merch := &model.Merch{Id: "item1", Price: 500, IsSelling: true}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("GetPromoCodeByCode", ctx, "WELCOME").Return(nil, cstErrors.NotFoundError)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "welcome")
+	assert.Equal(t, cstErrors.InvalidPromoCodeError, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+}
+
+func TestTransactionService_BuyItem_PromoExpired(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	expiredAt := time.Now().Add(-time.Hour)
+	merch := &model.Merch{Id: "item1", Price: 500, IsSelling: true}
+	promo := &model.PromoCode{Id: 1, Code: "OLD", DiscountType: model.PromoDiscountFixed, DiscountValue: 50, ExpiresAt: &expiredAt}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("GetPromoCodeByCode", ctx, "OLD").Return(promo, nil)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "OLD")
+	assert.Equal(t, cstErrors.InvalidPromoCodeError, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BuyItem_PromoNotApplicable(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	merch := &model.Merch{Id: "item1", Price: 500, IsSelling: true}
+	promo := &model.PromoCode{Id: 1, Code: "CUPS", DiscountType: model.PromoDiscountPercent, DiscountValue: 10, MerchIds: []string{"item2"}}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("GetPromoCodeByCode", ctx, "CUPS").Return(promo, nil)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "CUPS")
+	assert.Equal(t, cstErrors.PromoNotApplicableError, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_BuyItem_PromoUsedUp(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	merch := &model.Merch{Id: "item1", Price: 500, IsSelling: true}
+	promo := &model.PromoCode{Id: 1, Code: "ONCE", DiscountType: model.PromoDiscountFixed, DiscountValue: 100}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("GetPromoCodeByCode", ctx, "ONCE").Return(promo, nil)
+	mockRepo.On("RedeemPromoCode", ctx, int64(1)).Return(cstErrors.PromoLimitError)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "ONCE")
+	assert.Equal(t, cstErrors.PromoLimitError, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+}
+
+func TestTransactionService_BuyItem_PromoPerUserLimit(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	perUser := 1
+	merch := &model.Merch{Id: "item1", Price: 500, IsSelling: true}
+	promo := &model.PromoCode{Id: 1, Code: "ONCE", DiscountType: model.PromoDiscountFixed, DiscountValue: 100, MaxUsesPerUser: &perUser}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("GetPromoCodeByCode", ctx, "ONCE").Return(promo, nil)
+	mockRepo.On("RedeemPromoCode", ctx, int64(1)).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -400).Return(nil)
+	mockRepo.On("CountPromoRedemptions", ctx, int64(1), "user1").Return(1, nil)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "ONCE")
+	assert.Equal(t, cstErrors.PromoLimitError, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "LogBuyMerch")
+}
+
+func TestTransactionService_BuyItem_PromoOnSale(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	salePrice := 300
+	perUser := 2
+	merch := &model.Merch{Id: "item1", Price: 500, IsSelling: true, Sale: &model.PriceSchedule{SalePrice: &salePrice}}
+	promo := &model.PromoCode{Id: 1, Code: "HALF", DiscountType: model.PromoDiscountPercent, DiscountValue: 50,
+		MaxUsesPerUser: &perUser, MerchIds: []string{"item1"}}
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("GetPromoCodeByCode", ctx, "HALF").Return(promo, nil)
+	mockRepo.On("RedeemPromoCode", ctx, int64(1)).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -150).Return(nil)
+	mockRepo.On("CountPromoRedemptions", ctx, int64(1), "user1").Return(1, nil)
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user1", "item1", "", 150).Return(int64(10), nil)
+	mockRepo.On("LogPromoRedemption", ctx, int64(1), "user1", int64(10), 150).Return(nil)
+
+	err := ts.BuyItem(ctx, "user1", "item1", "", "HALF")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+// --- Tests for TransactionService.GiftItem ---
+
+func TestTransactionService_GiftItem_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	merch := &model.Merch{Id: "item1", Price: 100, IsSelling: true}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"bob"}).Return(map[string]string{"bob": "user2"}, nil)
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -100).Return(nil)
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user2", "item1", "", 100).Return(int64(1), nil)
+
+	err := ts.GiftItem(ctx, "user1", "bob", "item1", "", "")
+
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "UpdateBalance", ctx, "user2", mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_GiftItem_LimitCountsBuyer(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	limit := 1
+	merch := &model.Merch{Id: "item1", Price: 100, IsSelling: true, MaxPerUser: &limit}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"bob"}).Return(map[string]string{"bob": "user2"}, nil)
+	mockRepo.On("GetMerchById", ctx, "item1").Return(merch, nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -100).Return(nil)
+	mockRepo.On("CountUserPurchases", ctx, "user1", "item1").Return(1, nil)
+
+	err := ts.GiftItem(ctx, "user1", "bob", "item1", "", "")
+
+	assert.Equal(t, cstErrors.PurchaseLimitError, err)
+	mockRepo.AssertNotCalled(t, "LogBuyMerch")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_GiftItem_RecipientNotFound(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"ghost"}).Return(map[string]string{}, nil)
+
+	err := ts.GiftItem(ctx, "user1", "ghost", "item1", "", "")
+
+	assert.Equal(t, cstErrors.RecipientNotFoundError, err)
+	mockRepo.AssertNotCalled(t, "GetMerchById")
+}
+
+func TestTransactionService_GiftItem_SystemRecipient(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"system"}).Return(map[string]string{"system": model.SystemUserId}, nil)
+
+	err := ts.GiftItem(ctx, "user1", "system", "item1", "", "")
+
+	assert.Equal(t, cstErrors.RecipientNotFoundError, err)
+}
+
+func TestTransactionService_GiftItem_Yourself(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"alice"}).Return(map[string]string{"alice": "user1"}, nil)
+
+	err := ts.GiftItem(ctx, "user1", "alice", "item1", "", "")
+
+	assert.Equal(t, cstErrors.CantGiftYourselfError, err)
+	mockRepo.AssertNotCalled(t, "GetMerchById")
+}
+
+func TestTransactionService_GiftItem_EmptyRecipient(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	err := ts.GiftItem(ctx, "user1", "", "item1", "", "")
+
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	mockRepo.AssertNotCalled(t, "GetUserIdsByLogins")
+}
+
+// --- Tests for TransactionService.Checkout ---
+
+func TestTransactionService_Checkout_EmptyCart(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetCartItems", ctx, "user1").Return(nil, nil)
+
+	order, err := ts.Checkout(ctx, "user1")
+	assert.Equal(t, cstErrors.EmptyCartError, err)
+	assert.Nil(t, order)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_Checkout_NotSelling(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	items := []*model.CartItem{
+		{Merch: &model.Merch{Id: "item1", Price: 20, IsSelling: true}, Quantity: 1},
+		{Merch: &model.Merch{Id: "item2", Price: 50, IsSelling: false}, Quantity: 1},
+	}
+	mockRepo.On("GetCartItems", ctx, "user1").Return(items, nil)
+
+	order, err := ts.Checkout(ctx, "user1")
+	assert.Equal(t, cstErrors.NoSellingMerchError, err)
+	assert.Nil(t, order)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+}
+
+func TestTransactionService_Checkout_VariantRequired(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	items := []*model.CartItem{
+		{Merch: &model.Merch{Id: "item1", Price: 20, IsSelling: true, HasVariants: true}, Quantity: 1},
+	}
+	mockRepo.On("GetCartItems", ctx, "user1").Return(items, nil)
+
+	order, err := ts.Checkout(ctx, "user1")
+	assert.Equal(t, cstErrors.VariantRequiredError, err)
+	assert.Nil(t, order)
+	mockRepo.AssertNotCalled(t, "DecrementMerchStock")
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+}
+
+func TestTransactionService_Checkout_NoCoin(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	items := []*model.CartItem{
+		{Merch: &model.Merch{Id: "item1", Price: 500, IsSelling: true}, Quantity: 1},
+		{Merch: &model.Merch{Id: "item2", Price: 300, IsSelling: true}, Quantity: 2},
+	}
+	mockRepo.On("GetCartItems", ctx, "user1").Return(items, nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -1100).Return(cstErrors.NoCoinError)
+
+	order, err := ts.Checkout(ctx, "user1")
+	assert.Equal(t, cstErrors.NoCoinError, err)
+	assert.Nil(t, order)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "LogBuyMerch")
+	mockRepo.AssertNotCalled(t, "ClearCart")
+}
+
+func TestTransactionService_Checkout_PurchaseLimit(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	limit := 2
+	items := []*model.CartItem{
+		{Merch: &model.Merch{Id: "item1", Price: 20, IsSelling: true, MaxPerUser: &limit}, Quantity: 2},
+	}
+	mockRepo.On("GetCartItems", ctx, "user1").Return(items, nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -40).Return(nil)
+	mockRepo.On("CountUserPurchases", ctx, "user1", "item1").Return(1, nil)
+
+	order, err := ts.Checkout(ctx, "user1")
+	assert.Equal(t, cstErrors.PurchaseLimitError, err)
+	assert.Nil(t, order)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "LogBuyMerch")
+}
+
+func TestTransactionService_Checkout_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	stock := 10
+	percentOff := 50
+	items := []*model.CartItem{
+		{Merch: &model.Merch{Id: "item1", Name: "cup", Price: 20, IsSelling: true, Stock: &stock}, Quantity: 2},
+		{Merch: &model.Merch{Id: "item2", Name: "hoody", Price: 300, IsSelling: true,
+			Sale: &model.PriceSchedule{PercentOff: &percentOff}}, Quantity: 1},
+	}
+	mockRepo.On("GetCartItems", ctx, "user1").Return(items, nil)
+	mockRepo.On("DecrementMerchStock", ctx, "item1", 2).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -190).Return(nil)
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user1", "item1", "", 20).Return(int64(1), nil).Twice()
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user1", "item2", "", 150).Return(int64(3), nil).Once()
+	mockRepo.On("ClearCart", ctx, "user1").Return(nil)
+
+	order, err := ts.Checkout(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.CartResponse{
+		Items: []*model.CartLine{
+			{Item: "item1", Name: "cup", Quantity: 2, Price: 20, LineTotal: 40},
+			{Item: "item2", Name: "hoody", Quantity: 1, Price: 150, LineTotal: 150},
+		},
+		Total: 190,
+	}, order)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_Checkout_DecrementsStockInFixedOrder(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	stock := 10
+	items := []*model.CartItem{
+		{Merch: &model.Merch{Id: "item2", Name: "hoody", Price: 300, IsSelling: true, HasVariants: true},
+			Variant: &model.MerchVariant{Id: "variant2", MerchId: "item2", Stock: &stock}, Quantity: 1},
+		{Merch: &model.Merch{Id: "item1", Name: "cup", Price: 20, IsSelling: true, Stock: &stock}, Quantity: 1},
+		{Merch: &model.Merch{Id: "item2", Name: "hoody", Price: 300, IsSelling: true, HasVariants: true},
+			Variant: &model.MerchVariant{Id: "variant1", MerchId: "item2", Stock: &stock}, Quantity: 1},
+	}
+	var decremented []string
+	mockRepo.On("GetCartItems", ctx, "user1").Return(items, nil)
+	mockRepo.On("DecrementMerchStock", ctx, "item1", 1).Return(nil).
+		Run(func(mock.Arguments) { decremented = append(decremented, "item1") })
+	mockRepo.On("DecrementVariantStock", ctx, mock.AnythingOfType("string"), 1).Return(nil).
+		Run(func(args mock.Arguments) { decremented = append(decremented, args.String(1)) })
+	mockRepo.On("UpdateBalance", ctx, "user1", -620).Return(nil)
+	mockRepo.On("LogBuyMerch", ctx, "user1", "user1", mock.Anything, mock.Anything, mock.Anything).Return(int64(1), nil)
+	mockRepo.On("ClearCart", ctx, "user1").Return(nil)
+
+	order, err := ts.Checkout(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"item1", "variant1", "variant2"}, decremented)
+	// The order keeps lines in cart order
+	assert.Equal(t, "variant2", order.Items[0].VariantId)
+	mockRepo.AssertExpectations(t)
+}
+
+// --- Tests for TransactionService.GrantCoins ---
+
+func TestTransactionService_GrantCoins_BadRequest(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	err := ts.GrantCoins(ctx, "user1", 0, "bonus")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+
+	err = ts.GrantCoins(ctx, "user1", 100, "")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+
+	err = ts.GrantCoins(ctx, model.SystemUserId, 100, "bonus")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+}
+
+func TestTransactionService_GrantCoins_UserNotFound(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("LockUsers", ctx, []string{"user1"}).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", 100).Return(cstErrors.NotFoundError)
+
+	err := ts.GrantCoins(ctx, "user1", 100, "bonus")
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	mockRepo.AssertNotCalled(t, "LogTransaction")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_GrantCoins_LogTransactionError(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("LockUsers", ctx, []string{"user1"}).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", 100).Return(nil)
+	mockRepo.On("LogTransaction", ctx, mock.AnythingOfType("*model.Transaction")).Return(errors.New("log error"))
+
+	err := ts.GrantCoins(ctx, "user1", 100, "bonus")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "TransactionService.GrantCoins")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_GrantCoins_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("LockUsers", ctx, []string{"user1"}).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", 100).Return(nil)
+	mockRepo.On("LogTransaction", ctx, &model.Transaction{
+		FromUserId: model.SystemUserId,
+		ToUserId:   "user1",
+		Amount:     100,
+		Reason:     "contest prize",
+	}).Return(nil)
+
+	err := ts.GrantCoins(ctx, "user1", 100, "contest prize")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+// --- Tests for TransactionService.DeductCoins ---
+
+func TestTransactionService_DeductCoins_NoCoin(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("LockUsers", ctx, []string{"user1"}).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -100).Return(cstErrors.NoCoinError)
+
+	err := ts.DeductCoins(ctx, "user1", 100, "mistaken bonus")
+	assert.Equal(t, cstErrors.NoCoinError, err)
+	mockRepo.AssertNotCalled(t, "LogTransaction")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_DeductCoins_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("LockUsers", ctx, []string{"user1"}).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", -100).Return(nil)
+	mockRepo.On("LogTransaction", ctx, &model.Transaction{
+		FromUserId: "user1",
+		ToUserId:   model.SystemUserId,
+		Amount:     100,
+		Reason:     "mistaken bonus",
+	}).Return(nil)
+
+	err := ts.DeductCoins(ctx, "user1", 100, "mistaken bonus")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+// --- Tests for TransactionService.ReverseTransaction ---
+
+func TestTransactionService_ReverseTransaction_NoReason(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	reversal, err := ts.ReverseTransaction(ctx, 1, "")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, reversal)
+	mockRepo.AssertNotCalled(t, "GetTransactionById")
+}
+
+func TestTransactionService_ReverseTransaction_AlreadyReversed(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	reversedAt := time.Now()
+	orig := &model.Transaction{Id: 1, FromUserId: "user1", ToUserId: "user2", Amount: 100, ReversedAt: &reversedAt}
+	mockRepo.On("GetTransactionById", ctx, int64(1)).Return(orig, nil)
+
+	reversal, err := ts.ReverseTransaction(ctx, 1, "mistake")
+	assert.Equal(t, cstErrors.AlreadyReversedError, err)
+	assert.Nil(t, reversal)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReverseTransaction_ReversalEntry(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	orig := &model.Transaction{Id: 2, FromUserId: "user2", ToUserId: "user1", Amount: 100, ReversalOf: 1}
+	mockRepo.On("GetTransactionById", ctx, int64(2)).Return(orig, nil)
+
+	reversal, err := ts.ReverseTransaction(ctx, 2, "mistake")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, reversal)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReverseTransaction_Expiry(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	orig := &model.Transaction{Id: 2, FromUserId: "user1", ToUserId: model.SystemUserId, Amount: 40, Reason: "coins expired", Kind: model.TransactionKindExpiry}
+	mockRepo.On("GetTransactionById", ctx, int64(2)).Return(orig, nil)
+
+	reversal, err := ts.ReverseTransaction(ctx, 2, "mistake")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, reversal)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReverseTransaction_ConcurrentReversal(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	orig := &model.Transaction{Id: 1, FromUserId: "user1", ToUserId: "user2", Amount: 100}
+	mockRepo.On("GetTransactionById", ctx, int64(1)).Return(orig, nil)
+	mockRepo.On("LockUsers", ctx, []string{"user1", "user2"}).Return(nil)
+	mockRepo.On("MarkTransactionReversed", ctx, int64(1)).Return(cstErrors.AlreadyReversedError)
+
+	reversal, err := ts.ReverseTransaction(ctx, 1, "mistake")
+	assert.Equal(t, cstErrors.AlreadyReversedError, err)
+	assert.Nil(t, reversal)
+	mockRepo.AssertNotCalled(t, "TransferCoins")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReverseTransaction_RecipientSpentCoins(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	orig := &model.Transaction{Id: 1, FromUserId: "user1", ToUserId: "user2", Amount: 100}
+	mockRepo.On("GetTransactionById", ctx, int64(1)).Return(orig, nil)
+	mockRepo.On("LockUsers", ctx, []string{"user1", "user2"}).Return(nil)
+	mockRepo.On("MarkTransactionReversed", ctx, int64(1)).Return(nil)
+	mockRepo.On("TransferCoins", ctx, "user2", "user1", 100).Return(cstErrors.NoCoinError)
+
+	reversal, err := ts.ReverseTransaction(ctx, 1, "mistake")
+	assert.Equal(t, cstErrors.NoCoinError, err)
+	assert.Nil(t, reversal)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReverseTransaction_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	orig := &model.Transaction{Id: 1, FromUserId: "user1", ToUserId: "user2", Amount: 100}
+	mockRepo.On("GetTransactionById", ctx, int64(1)).Return(orig, nil)
+	mockRepo.On("LockUsers", ctx, []string{"user1", "user2"}).Return(nil)
+	mockRepo.On("MarkTransactionReversed", ctx, int64(1)).Return(nil)
+	mockRepo.On("TransferCoins", ctx, "user2", "user1", 100).Return(nil)
+	expected := &model.Transaction{FromUserId: "user2", ToUserId: "user1", Amount: 100, Reason: "mistake", ReversalOf: 1}
+	mockRepo.On("LogTransaction", ctx, expected).Return(nil)
+
+	reversal, err := ts.ReverseTransaction(ctx, 1, "mistake")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, reversal)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReverseTransaction_Grant(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	orig := &model.Transaction{Id: 1, FromUserId: model.SystemUserId, ToUserId: "user2", Amount: 100}
+	mockRepo.On("GetTransactionById", ctx, int64(1)).Return(orig, nil)
+	mockRepo.On("LockUsers", ctx, []string{"user2"}).Return(nil)
+	mockRepo.On("MarkTransactionReversed", ctx, int64(1)).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user2", -100).Return(nil)
+	mockRepo.On("LogTransaction", ctx, mock.AnythingOfType("*model.Transaction")).Return(nil)
+
+	reversal, err := ts.ReverseTransaction(ctx, 1, "mistaken bonus")
+	assert.NoError(t, err)
+	assert.Equal(t, model.SystemUserId, reversal.ToUserId)
+	mockRepo.AssertNumberOfCalls(t, "UpdateBalance", 1)
+	mockRepo.AssertExpectations(t)
+}
+
+// --- Tests for TransactionService.ReversePurchase ---
+
+func TestTransactionService_ReversePurchase_NotFound(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(nil, cstErrors.NotFoundError)
+
+	refund, err := ts.ReversePurchase(ctx, 7, "defective")
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	assert.Nil(t, refund)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReversePurchase_AlreadyReversed(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	reversedAt := time.Now()
+	purchase := &model.Purchase{Id: 7, UserId: "user1", BuyerId: "user1", MerchId: "item1", Price: 500, ReversedAt: &reversedAt}
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(purchase, nil)
+
+	refund, err := ts.ReversePurchase(ctx, 7, "defective")
+	assert.Equal(t, cstErrors.AlreadyReversedError, err)
+	assert.Nil(t, refund)
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReversePurchase_LogError(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	purchase := &model.Purchase{Id: 7, UserId: "user1", BuyerId: "user1", MerchId: "item1", Price: 500}
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(purchase, nil)
+	mockRepo.On("MarkPurchaseReversed", ctx, int64(7)).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", 500).Return(nil)
+	mockRepo.On("IncrementMerchStock", ctx, "item1", 1).Return(nil)
+	mockRepo.On("ReleasePromoRedemption", ctx, int64(7)).Return(nil)
+	mockRepo.On("LogTransaction", ctx, mock.AnythingOfType("*model.Transaction")).Return(errors.New("log error"))
+
+	refund, err := ts.ReversePurchase(ctx, 7, "defective")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "TransactionService.ReversePurchase")
+	assert.Nil(t, refund)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReversePurchase_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	purchase := &model.Purchase{Id: 7, UserId: "user1", BuyerId: "user1", MerchId: "item1", Price: 500}
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(purchase, nil)
+	mockRepo.On("MarkPurchaseReversed", ctx, int64(7)).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", 500).Return(nil)
+	mockRepo.On("IncrementMerchStock", ctx, "item1", 1).Return(nil)
+	mockRepo.On("ReleasePromoRedemption", ctx, int64(7)).Return(nil)
+	expected := &model.Transaction{FromUserId: model.SystemUserId, ToUserId: "user1", Amount: 500, Reason: "defective", PurchaseId: 7}
+	mockRepo.On("LogTransaction", ctx, expected).Return(nil)
+
+	refund, err := ts.ReversePurchase(ctx, 7, "defective")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, refund)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReversePurchase_ReleasesPromoUse(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	purchase := &model.Purchase{Id: 8, UserId: "user1", BuyerId: "user1", MerchId: "item1", Price: 400}
+	mockRepo.On("GetPurchaseById", ctx, int64(8)).Return(purchase, nil)
+	mockRepo.On("MarkPurchaseReversed", ctx, int64(8)).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", 400).Return(nil)
+	mockRepo.On("IncrementMerchStock", ctx, "item1", 1).Return(nil)
+	mockRepo.On("ReleasePromoRedemption", ctx, int64(8)).Return(errors.New("db error"))
+
+	refund, err := ts.ReversePurchase(ctx, 8, "defective")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "TransactionService.ReversePurchase")
+	assert.Nil(t, refund)
+	mockRepo.AssertNotCalled(t, "LogTransaction")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ReversePurchase_GiftRefundsBuyer(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	purchase := &model.Purchase{Id: 7, UserId: "user2", BuyerId: "user1", MerchId: "item1", Price: 500}
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(purchase, nil)
+	mockRepo.On("MarkPurchaseReversed", ctx, int64(7)).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", 500).Return(nil)
+	mockRepo.On("IncrementMerchStock", ctx, "item1", 1).Return(nil)
+	mockRepo.On("ReleasePromoRedemption", ctx, int64(7)).Return(nil)
+	mockRepo.On("LogTransaction", ctx, mock.AnythingOfType("*model.Transaction")).Return(nil)
+
+	_, err := ts.ReversePurchase(ctx, 7, "gift returned")
+
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "UpdateBalance", ctx, "user2", 500)
+	mockRepo.AssertExpectations(t)
+}
+
+// --- Tests for TransactionService.ChangeOrderStatus ---
+
+func TestTransactionService_ChangeOrderStatus_BadStatus(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	err := ts.ChangeOrderStatus(ctx, 7, "lost", "")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	mockRepo.AssertNotCalled(t, "GetPurchaseById")
+}
+
+func TestTransactionService_ChangeOrderStatus_NotFound(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(nil, cstErrors.NotFoundError)
+
+	err := ts.ChangeOrderStatus(ctx, 7, model.OrderStatusReadyForPickup, "")
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ChangeOrderStatus_InvalidTransition(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	transitions := []struct{ from, to string }{
+		{model.OrderStatusPlaced, model.OrderStatusDelivered},
+		{model.OrderStatusDelivered, model.OrderStatusCancelled},
+		{model.OrderStatusCancelled, model.OrderStatusReadyForPickup},
+		{model.OrderStatusReadyForPickup, model.OrderStatusPlaced},
+	}
+	for i, tr := range transitions {
+		id := int64(i + 1)
+		purchase := &model.Purchase{Id: id, UserId: "user1", BuyerId: "user1", Price: 500, Status: tr.from}
+		mockRepo.On("GetPurchaseById", ctx, id).Return(purchase, nil)
+
+		err := ts.ChangeOrderStatus(ctx, id, tr.to, "")
+		assert.Equal(t, cstErrors.OrderStatusError, err, "%s -> %s", tr.from, tr.to)
+	}
+	mockRepo.AssertNotCalled(t, "SetPurchaseStatus")
+	mockRepo.AssertNotCalled(t, "UpdateBalance")
+}
+
+func TestTransactionService_ChangeOrderStatus_Advance(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	purchase := &model.Purchase{Id: 7, UserId: "user1", BuyerId: "user1", Price: 500, Status: model.OrderStatusReadyForPickup}
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(purchase, nil)
+	mockRepo.On("SetPurchaseStatus", ctx, int64(7), model.OrderStatusDelivered).Return(nil)
+
+	err := ts.ChangeOrderStatus(ctx, 7, model.OrderStatusDelivered, "")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_ChangeOrderStatus_CancelRefunds(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	purchase := &model.Purchase{
+		Id:        7,
+		UserId:    "user1",
+		BuyerId:   "user1",
+		MerchId:   "item1",
+		VariantId: "variant1",
+		Price:     500,
+		Status:    model.OrderStatusPlaced,
+	}
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(purchase, nil)
+	mockRepo.On("MarkPurchaseReversed", ctx, int64(7)).Return(nil)
+	mockRepo.On("UpdateBalance", ctx, "user1", 500).Return(nil)
+	mockRepo.On("IncrementVariantStock", ctx, "variant1", 1).Return(nil)
+	mockRepo.On("ReleasePromoRedemption", ctx, int64(7)).Return(nil)
+	expected := &model.Transaction{FromUserId: model.SystemUserId, ToUserId: "user1", Amount: 500, Reason: defaultCancelReason, PurchaseId: 7}
+	mockRepo.On("LogTransaction", ctx, expected).Return(nil)
+
+	err := ts.ChangeOrderStatus(ctx, 7, model.OrderStatusCancelled, "")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "SetPurchaseStatus")
+	mockRepo.AssertNotCalled(t, "IncrementMerchStock")
+}
+
+// --- Tests for TransactionService.TransferItem ---
+
+func TestTransactionService_TransferItem_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	purchase := &model.Purchase{Id: 7, UserId: "user1", BuyerId: "user1", Price: 500, Status: model.OrderStatusDelivered}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"bob"}).Return(map[string]string{"bob": "user2"}, nil)
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(purchase, nil)
+	mockRepo.On("SetPurchaseOwner", ctx, int64(7), "user2").Return(nil)
+	mockRepo.On("LogItemTransfer", ctx, int64(7), "user1", "user2").Return(nil)
+
+	err := ts.TransferItem(ctx, "user1", 7, "bob")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_TransferItem_NotOwner(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	purchase := &model.Purchase{Id: 7, UserId: "user3", BuyerId: "user1", Price: 500, Status: model.OrderStatusPlaced}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"bob"}).Return(map[string]string{"bob": "user2"}, nil)
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(purchase, nil)
+
+	err := ts.TransferItem(ctx, "user1", 7, "bob")
+
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	mockRepo.AssertNotCalled(t, "SetPurchaseOwner")
+}
+
+func TestTransactionService_TransferItem_Cancelled(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	reversedAt := time.Now()
+	purchase := &model.Purchase{Id: 7, UserId: "user1", BuyerId: "user1", Price: 500, Status: model.OrderStatusCancelled, ReversedAt: &reversedAt}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"bob"}).Return(map[string]string{"bob": "user2"}, nil)
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(purchase, nil)
+
+	err := ts.TransferItem(ctx, "user1", 7, "bob")
+
+	assert.Equal(t, cstErrors.ItemNotTransferableError, err)
+	mockRepo.AssertNotCalled(t, "SetPurchaseOwner")
+}
+
+func TestTransactionService_TransferItem_Yourself(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"alice"}).Return(map[string]string{"alice": "user1"}, nil)
+
+	err := ts.TransferItem(ctx, "user1", 7, "alice")
+
+	assert.Equal(t, cstErrors.CantTransferYourselfError, err)
+	mockRepo.AssertNotCalled(t, "GetPurchaseById")
+}
+
+func TestTransactionService_TransferItem_RecipientNotFound(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"ghost"}).Return(map[string]string{}, nil)
+
+	err := ts.TransferItem(ctx, "user1", 7, "ghost")
+
+	assert.Equal(t, cstErrors.RecipientNotFoundError, err)
+	mockRepo.AssertNotCalled(t, "GetPurchaseById")
+}
+
+func TestTransactionService_TransferItem_LogError(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	purchase := &model.Purchase{Id: 7, UserId: "user1", BuyerId: "user1", Price: 500, Status: model.OrderStatusPlaced}
+	mockRepo.On("GetUserIdsByLogins", ctx, []string{"bob"}).Return(map[string]string{"bob": "user2"}, nil)
+	mockRepo.On("GetPurchaseById", ctx, int64(7)).Return(purchase, nil)
+	mockRepo.On("SetPurchaseOwner", ctx, int64(7), "user2").Return(nil)
+	mockRepo.On("LogItemTransfer", ctx, int64(7), "user1", "user2").Return(errors.New("db error"))
+
+	err := ts.TransferItem(ctx, "user1", 7, "bob")
+
+	assert.Error(t, err)
+	assert.False(t, cstErrors.IsCustomError(err))
+}
+
+// --- Tests for TransactionService.GetPurchases ---
+
+func TestTransactionService_GetPurchases_Error(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetUserPurchases", ctx, "user1").Return(nil, errors.New("db error"))
+
+	purchases, err := ts.GetPurchases(ctx, "user1")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "TransactionService.GetPurchases")
+	assert.Nil(t, purchases)
+	mockRepo.AssertExpectations(t)
+}
+
+// --- Tests for TransactionService.GetTransactionsHistory ---
+
+func TestTransactionService_GetTransactionsHistory_ReceivedError(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	mockRepo.On("GetTransactionHistoryReceived", ctx, "user1", "").Return([]*model.ReceivedCoin(nil), errors.New("received error"))
+
+	history, err := ts.GetTransactionsHistory(ctx, "user1", "")
+	assert.Error(t, err)
+	assert.Nil(t, history)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_GetTransactionsHistory_SentError(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	received := []*model.ReceivedCoin{{Amount: 50}} // имеются полученные транзакции
+	mockRepo.On("GetTransactionHistoryReceived", ctx, "user1", "").Return(received, nil)
+	mockRepo.On("GetTransactionHistorySent", ctx, "user1", "").Return([]*model.SentCoin(nil), errors.New("sent error"))
+
+	history, err := ts.GetTransactionsHistory(ctx, "user1", "")
+	assert.Error(t, err)
+	assert.Nil(t, history)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_GetTransactionsHistory_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	received := []*model.ReceivedCoin{{Amount: 50}}
+	sent := []*model.SentCoin{{Amount: 30}}
+
+	mockRepo.On("GetTransactionHistoryReceived", ctx, "user1", "").Return(received, nil)
+	mockRepo.On("GetTransactionHistorySent", ctx, "user1", "").Return(sent, nil)
+
+	history, err := ts.GetTransactionsHistory(ctx, "user1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, received, history.Received)
+	assert.Equal(t, sent, history.Sent)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransactionService_GetTransactionsHistory_BadCategory(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	history, err := ts.GetTransactionsHistory(ctx, "user1", "bribe")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, history)
+	mockRepo.AssertNotCalled(t, "GetTransactionHistoryReceived")
+}
+
+func TestTransactionService_GetTransactionsHistory_Category(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	ts := NewTransactionService(mockRepo)
+	ctx := context.Background()
+
+	received := []*model.ReceivedCoin{{Amount: 50, Category: model.TransferCategoryGift}}
+	mockRepo.On("GetTransactionHistoryReceived", ctx, "user1", model.TransferCategoryGift).Return(received, nil)
+	mockRepo.On("GetTransactionHistorySent", ctx, "user1", model.TransferCategoryGift).Return([]*model.SentCoin(nil), nil)
+
+	history, err := ts.GetTransactionsHistory(ctx, "user1", model.TransferCategoryGift)
+	assert.NoError(t, err)
+	assert.Equal(t, received, history.Received)
+	assert.Empty(t, history.Sent)
 	mockRepo.AssertExpectations(t)
 }
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/utils"
 )
 
@@ -14,6 +15,7 @@ type UserRepository interface {
 
 	CreateUser(ctx context.Context, user *model.User) (*model.User, error)
 	GetUserById(ctx context.Context, id string) (*model.User, error)
+	SetUserAdmin(ctx context.Context, userId string, isAdmin bool) error
 }
 
 type UserService struct {
@@ -26,6 +28,9 @@ func NewUserService(repo UserRepository) *UserService {
 
 func (u *UserService) Login(ctx context.Context, username, password string) (string, error) {
 	const op = "UserService.Login"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
 	if username == "" || password == "" {
 		return "", cstErrors.BadRequestDataError
 	}
@@ -61,6 +66,9 @@ func (u *UserService) Login(ctx context.Context, username, password string) (str
 
 func (u *UserService) Register(ctx context.Context, user *model.User) (*model.User, error) {
 	const op = "UserService.Register"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
 	hashedPassword, err := utils.HashPassword(user.Password)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -72,6 +80,28 @@ func (u *UserService) Register(ctx context.Context, user *model.User) (*model.Us
 	}
 	user, err = u.repo.CreateUser(ctx, user)
 	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+func (u *UserService) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	const op = "UserService.GetUserByUsername"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if username == "" {
+		return nil, cstErrors.BadRequestDataError
+	}
+
+	user, err := u.repo.GetUserByLogin(ctx, username)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	return user, nil
@@ -79,6 +109,9 @@ func (u *UserService) Register(ctx context.Context, user *model.User) (*model.Us
 
 func (u *UserService) GetUserBalance(ctx context.Context, userId string) (int, error) {
 	const op = "UserService.GetUserBalance"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
 	user, err := u.repo.GetUserById(ctx, userId)
 	if err != nil {
 		if cstErrors.IsCustomError(err) {
@@ -88,3 +121,35 @@ func (u *UserService) GetUserBalance(ctx context.Context, userId string) (int, e
 	}
 	return user.Balance, nil
 }
+
+func (u *UserService) IsAdmin(ctx context.Context, userId string) (bool, error) {
+	const op = "UserService.IsAdmin"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	user, err := u.repo.GetUserById(ctx, userId)
+	if err != nil {
+		if cstErrors.IsCustomError(err) {
+			return false, err
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return user.IsAdmin, nil
+}
+
+func (u *UserService) SetAdmin(ctx context.Context, userId string, isAdmin bool) error {
+	const op = "UserService.SetAdmin"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if userId == model.SystemUserId {
+		return cstErrors.BadRequestDataError
+	}
+	if err := u.repo.SetUserAdmin(ctx, userId, isAdmin); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -41,6 +41,11 @@ func (m *MockUserRepository) GetUserById(ctx context.Context, id string) (*model
 	return nil, args.Error(1)
 }
 
+func (m *MockUserRepository) SetUserAdmin(ctx context.Context, userId string, isAdmin bool) error {
+	args := m.Called(ctx, userId, isAdmin)
+	return args.Error(0)
+}
+
 // --- Tests for UserService.Login ---
 
 func TestUserService_Login_BadRequest(t *testing.T) {
@@ -182,3 +187,95 @@ func TestUserService_GetUserBalance_Success(t *testing.T) {
 	assert.Equal(t, 1000, balance)
 	repo.AssertExpectations(t)
 }
+
+// --- Tests for UserService.GetUserByUsername ---
+
+func TestUserService_GetUserByUsername_BadRequest(t *testing.T) {
+	repo := new(MockUserRepository)
+	svc := NewUserService(repo)
+	ctx := context.Background()
+
+	user, err := svc.GetUserByUsername(ctx, "")
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	assert.Nil(t, user)
+}
+
+func TestUserService_GetUserByUsername_NotFound(t *testing.T) {
+	repo := new(MockUserRepository)
+	svc := NewUserService(repo)
+	ctx := context.Background()
+
+	repo.On("GetUserByLogin", ctx, "ghost").Return(nil, cstErrors.NotFoundError)
+
+	user, err := svc.GetUserByUsername(ctx, "ghost")
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	assert.Nil(t, user)
+	repo.AssertExpectations(t)
+}
+
+func TestUserService_GetUserByUsername_Success(t *testing.T) {
+	repo := new(MockUserRepository)
+	svc := NewUserService(repo)
+	ctx := context.Background()
+
+	existingUser := &model.User{Id: "123", Username: "existing"}
+	repo.On("GetUserByLogin", ctx, "existing").Return(existingUser, nil)
+
+	user, err := svc.GetUserByUsername(ctx, "existing")
+	assert.NoError(t, err)
+	assert.Equal(t, existingUser, user)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for UserService.IsAdmin ---
+
+func TestUserService_IsAdmin_Error(t *testing.T) {
+	repo := new(MockUserRepository)
+	svc := NewUserService(repo)
+	ctx := context.Background()
+
+	repo.On("GetUserById", ctx, "123").Return(nil, errors.New("db error"))
+
+	isAdmin, err := svc.IsAdmin(ctx, "123")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "UserService.IsAdmin")
+	assert.False(t, isAdmin)
+	repo.AssertExpectations(t)
+}
+
+func TestUserService_IsAdmin_Success(t *testing.T) {
+	repo := new(MockUserRepository)
+	svc := NewUserService(repo)
+	ctx := context.Background()
+
+	repo.On("GetUserById", ctx, "123").Return(&model.User{Id: "123", IsAdmin: true}, nil)
+
+	isAdmin, err := svc.IsAdmin(ctx, "123")
+	assert.NoError(t, err)
+	assert.True(t, isAdmin)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for UserService.SetAdmin ---
+
+func TestUserService_SetAdmin_SystemUser(t *testing.T) {
+	repo := new(MockUserRepository)
+	svc := NewUserService(repo)
+	ctx := context.Background()
+
+	err := svc.SetAdmin(ctx, model.SystemUserId, true)
+	assert.Equal(t, cstErrors.BadRequestDataError, err)
+	repo.AssertNotCalled(t, "SetUserAdmin")
+}
+
+func TestUserService_SetAdmin_Success(t *testing.T) {
+	repo := new(MockUserRepository)
+	svc := NewUserService(repo)
+	ctx := context.Background()
+
+	repo.On("SetUserAdmin", ctx, "123", true).Return(nil)
+
+	err := svc.SetAdmin(ctx, "123", true)
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+)
+
+type variantGetter interface {
+	GetMerchVariant(ctx context.Context, id string) (*model.MerchVariant, error)
+}
+
+// resolveVariant checks that the variant belongs to the merch. Merch sold by variants
+// cannot be bought without one, for other merch an empty variantId gives a nil variant.
+func resolveVariant(ctx context.Context, repo variantGetter, merch *model.Merch, variantId string) (*model.MerchVariant, error) {
+	if variantId == "" {
+		if merch.HasVariants {
+			return nil, cstErrors.VariantRequiredError
+		}
+		return nil, nil
+	}
+
+	variant, err := repo.GetMerchVariant(ctx, variantId)
+	if err != nil {
+		return nil, err
+	}
+	if variant.MerchId != merch.Id {
+		return nil, cstErrors.NotFoundError
+	}
+	return variant, nil
+}
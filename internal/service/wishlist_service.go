@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/tracing"
+)
+
+type WishlistRepository interface {
+	GetMerchById(ctx context.Context, itemId string) (*model.Merch, error)
+
+	AddToWishlist(ctx context.Context, userId, merchId string) error
+	RemoveFromWishlist(ctx context.Context, userId, merchId string) error
+	GetWishlist(ctx context.Context, userId string) ([]*model.WishlistEntry, error)
+}
+
+type WishlistService struct {
+	repo WishlistRepository
+}
+
+func NewWishlistService(repo WishlistRepository) *WishlistService {
+	return &WishlistService{repo: repo}
+}
+
+func (w *WishlistService) AddItem(ctx context.Context, userId, itemId string) error {
+	const op = "WishlistService.AddItem"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if _, err := w.repo.GetMerchById(ctx, itemId); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := w.repo.AddToWishlist(ctx, userId, itemId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (w *WishlistService) RemoveItem(ctx context.Context, userId, itemId string) error {
+	const op = "WishlistService.RemoveItem"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := w.repo.RemoveFromWishlist(ctx, userId, itemId); err != nil {
+		if cstErrors.IsCustomError(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetWishlist lists saved merch at current prices and how many coins the user
+// with the given balance still lacks for each item
+func (w *WishlistService) GetWishlist(ctx context.Context, userId string, balance int) ([]*model.WishlistItem, error) {
+	const op = "WishlistService.GetWishlist"
+
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	entries, err := w.repo.GetWishlist(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	wishlist := make([]*model.WishlistItem, 0, len(entries))
+	for _, entry := range entries {
+		price := entry.Merch.EffectivePrice()
+		wishlist = append(wishlist, &model.WishlistItem{
+			Item:        entry.Merch.Id,
+			Name:        entry.Merch.Name,
+			Price:       price,
+			IsSelling:   entry.Merch.IsSelling,
+			CoinsNeeded: max(price-balance, 0),
+			AddedAt:     entry.AddedAt,
+		})
+	}
+	return wishlist, nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+)
+
+type MockWishlistRepository struct {
+	mock.Mock
+}
+
+func (m *MockWishlistRepository) GetMerchById(ctx context.Context, itemId string) (*model.Merch, error) {
+	args := m.Called(ctx, itemId)
+	if merch := args.Get(0); merch != nil {
+		return merch.(*model.Merch), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWishlistRepository) AddToWishlist(ctx context.Context, userId, merchId string) error {
+	args := m.Called(ctx, userId, merchId)
+	return args.Error(0)
+}
+
+func (m *MockWishlistRepository) RemoveFromWishlist(ctx context.Context, userId, merchId string) error {
+	args := m.Called(ctx, userId, merchId)
+	return args.Error(0)
+}
+
+func (m *MockWishlistRepository) GetWishlist(ctx context.Context, userId string) ([]*model.WishlistEntry, error) {
+	args := m.Called(ctx, userId)
+	if list := args.Get(0); list != nil {
+		return list.([]*model.WishlistEntry), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// --- Tests for WishlistService.AddItem ---
+
+func TestWishlistService_AddItem_NotFound(t *testing.T) {
+	repo := new(MockWishlistRepository)
+	svc := NewWishlistService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchById", ctx, "ghost").Return(nil, cstErrors.NotFoundError)
+
+	err := svc.AddItem(ctx, "user1", "ghost")
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "AddToWishlist", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWishlistService_AddItem_Success(t *testing.T) {
+	repo := new(MockWishlistRepository)
+	svc := NewWishlistService(repo)
+	ctx := context.Background()
+
+	repo.On("GetMerchById", ctx, "item1").Return(&model.Merch{Id: "item1", Name: "hoody"}, nil)
+	repo.On("AddToWishlist", ctx, "user1", "item1").Return(nil)
+
+	err := svc.AddItem(ctx, "user1", "item1")
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for WishlistService.RemoveItem ---
+
+func TestWishlistService_RemoveItem_NotInWishlist(t *testing.T) {
+	repo := new(MockWishlistRepository)
+	svc := NewWishlistService(repo)
+	ctx := context.Background()
+
+	repo.On("RemoveFromWishlist", ctx, "user1", "item1").Return(cstErrors.NotFoundError)
+
+	err := svc.RemoveItem(ctx, "user1", "item1")
+	assert.Equal(t, cstErrors.NotFoundError, err)
+	repo.AssertExpectations(t)
+}
+
+// --- Tests for WishlistService.GetWishlist ---
+
+func TestWishlistService_GetWishlist_Error(t *testing.T) {
+	repo := new(MockWishlistRepository)
+	svc := NewWishlistService(repo)
+	ctx := context.Background()
+
+	repo.On("GetWishlist", ctx, "user1").Return(nil, errors.New("db error"))
+
+	wishlist, err := svc.GetWishlist(ctx, "user1", 100)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "WishlistService.GetWishlist")
+	assert.Nil(t, wishlist)
+	repo.AssertExpectations(t)
+}
+
+func TestWishlistService_GetWishlist_CoinsNeeded(t *testing.T) {
+	repo := new(MockWishlistRepository)
+	svc := NewWishlistService(repo)
+	ctx := context.Background()
+
+	addedAt := time.Now()
+	percentOff := 50
+	entries := []*model.WishlistEntry{
+		{Merch: &model.Merch{Id: "item1", Name: "pink-hoody", Price: 500, IsSelling: true}, AddedAt: addedAt},
+		{Merch: &model.Merch{Id: "item2", Name: "hoody", Price: 300, IsSelling: true,
+			Sale: &model.PriceSchedule{PercentOff: &percentOff}}, AddedAt: addedAt},
+		{Merch: &model.Merch{Id: "item3", Name: "cup", Price: 20}, AddedAt: addedAt},
+	}
+	repo.On("GetWishlist", ctx, "user1").Return(entries, nil)
+
+	wishlist, err := svc.GetWishlist(ctx, "user1", 200)
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.WishlistItem{
+		{Item: "item1", Name: "pink-hoody", Price: 500, IsSelling: true, CoinsNeeded: 300, AddedAt: addedAt},
+		{Item: "item2", Name: "hoody", Price: 150, IsSelling: true, CoinsNeeded: 0, AddedAt: addedAt},
+		{Item: "item3", Name: "cup", Price: 20, IsSelling: false, CoinsNeeded: 0, AddedAt: addedAt},
+	}, wishlist)
+	repo.AssertExpectations(t)
+}
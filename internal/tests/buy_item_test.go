@@ -3,10 +3,12 @@ package tests
 import (
 	"context"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/config"
+	cstErrors "github.com/ArtemSarafannikov/AvitoTestTask/internal/error"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/repository"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/service"
 	"github.com/ArtemSarafannikov/AvitoTestTask/internal/utils"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -39,7 +41,7 @@ func Test_BuyItem_Success(t *testing.T) {
 	merch, err = repo.CreateMerch(ctx, merch)
 	require.NoError(t, err)
 
-	err = ts.BuyItem(ctx, user.Id, merch.Id)
+	err = ts.BuyItem(ctx, user.Id, merch.Id, "", "")
 	require.NoError(t, err)
 
 	updatedUser, err := repo.GetUserById(ctx, user.Id)
@@ -51,3 +53,51 @@ func Test_BuyItem_Success(t *testing.T) {
 	assert.Len(t, inventory, 1)
 	assert.Equal(t, merch.Name, inventory[0].Type)
 }
+
+func Test_BuyItem_OutOfStock(t *testing.T) {
+	cfg := config.MustLoad()
+
+	repo, err := repository.NewPostgresRepository(cfg.Storage)
+	require.NoError(t, err)
+
+	ts := service.NewTransactionService(repo)
+
+	ctx := context.Background()
+
+	suffix := uuid.NewString()
+
+	hashedPassword, err := utils.HashPassword("test_password")
+	require.NoError(t, err)
+	user := &model.User{
+		Username: "stock_test_user_" + suffix,
+		Password: hashedPassword,
+		Balance:  1000,
+	}
+	user, err = repo.CreateUser(ctx, user)
+	require.NoError(t, err)
+
+	stock := 1
+	merch := &model.Merch{
+		Name:      "Limited Test Item " + suffix,
+		Price:     100,
+		IsSelling: true,
+		Stock:     &stock,
+	}
+	merch, err = repo.CreateMerch(ctx, merch)
+	require.NoError(t, err)
+
+	err = ts.BuyItem(ctx, user.Id, merch.Id, "", "")
+	require.NoError(t, err)
+
+	err = ts.BuyItem(ctx, user.Id, merch.Id, "", "")
+	assert.Equal(t, cstErrors.OutOfStockError, err)
+
+	updatedUser, err := repo.GetUserById(ctx, user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 900, updatedUser.Balance)
+
+	updatedMerch, err := repo.GetMerchById(ctx, merch.Id)
+	require.NoError(t, err)
+	require.NotNil(t, updatedMerch.Stock)
+	assert.Equal(t, 0, *updatedMerch.Stock)
+}
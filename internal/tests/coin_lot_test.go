@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"context"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/config"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/model"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/repository"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/service"
+	"github.com/ArtemSarafannikov/AvitoTestTask/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_SendCoin_AllowanceExpiresAtRecipient(t *testing.T) {
+	cfg := config.MustLoad()
+
+	repo, err := repository.NewPostgresRepository(cfg.Storage)
+	require.NoError(t, err)
+
+	ts := service.NewTransactionService(repo)
+
+	ctx := context.Background()
+
+	suffix := uuid.NewString()
+	hashedPassword, err := utils.HashPassword("test_password")
+	require.NoError(t, err)
+
+	sender, err := repo.CreateUser(ctx, &model.User{Username: "lot_sender_" + suffix, Password: hashedPassword})
+	require.NoError(t, err)
+	receiver, err := repo.CreateUser(ctx, &model.User{Username: "lot_receiver_" + suffix, Password: hashedPassword, Balance: 200})
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	periodStart := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	_, err = repo.IssueAllowance(ctx, 100, periodStart, now.Add(time.Hour), "allowance")
+	require.NoError(t, err)
+
+	err = ts.SendCoin(ctx, sender.Id, receiver.Id, 60, "", "")
+	require.NoError(t, err)
+
+	updatedReceiver, err := repo.GetUserById(ctx, receiver.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 360, updatedReceiver.Balance)
+
+	_, err = repo.ExpireCoinLots(ctx, now.Add(2*time.Hour), "coins expired")
+	require.NoError(t, err)
+
+	// The receiver keeps only the coins that had no expiry
+	updatedReceiver, err = repo.GetUserById(ctx, receiver.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 200, updatedReceiver.Balance)
+
+	updatedSender, err := repo.GetUserById(ctx, sender.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 0, updatedSender.Balance)
+}
@@ -37,7 +37,7 @@ func Test_SendCoin_Success(t *testing.T) {
 	receiver, err = repo.CreateUser(ctx, receiver)
 	require.NoError(t, err)
 
-	err = ts.SendCoin(ctx, sender.Id, receiver.Id, 300)
+	err = ts.SendCoin(ctx, sender.Id, receiver.Id, 300, "thanks for the code review", model.TransferCategoryKudos)
 	require.NoError(t, err)
 
 	updatedSender, err := repo.GetUserById(ctx, sender.Id)
@@ -48,9 +48,11 @@ func Test_SendCoin_Success(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 500, updatedReceiver.Balance)
 
-	history, err := repo.GetTransactionHistorySent(ctx, sender.Id)
+	history, err := repo.GetTransactionHistorySent(ctx, sender.Id, "")
 	require.NoError(t, err)
 	assert.Len(t, history, 1)
 	assert.Equal(t, receiver.Username, history[0].ToUser)
 	assert.Equal(t, 300, history[0].Amount)
+	assert.Equal(t, "thanks for the code review", history[0].Message)
+	assert.Equal(t, model.TransferCategoryKudos, history[0].Category)
 }
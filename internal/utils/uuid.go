@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// GenerateUUID returns a random RFC 4122 version 4 UUID
+func GenerateUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// IsValidUUID reports whether s is a UUID in any form Postgres accepts for the uuid type
+func IsValidUUID(s string) bool {
+	_, err := uuid.Parse(s)
+	return err == nil
+}
@@ -5,6 +5,7 @@ CREATE TABLE IF NOT EXISTS users (
     login VARCHAR UNIQUE NOT NULL,
     password VARCHAR NOT NULL,
     balance INT NOT NULL CHECK (balance >= 0),
+    is_admin BOOLEAN NOT NULL DEFAULT false,
     created_at TIMESTAMP DEFAULT now()
 );
 
@@ -13,6 +14,13 @@ CREATE TABLE IF NOT EXISTS transactions (
     from_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
     to_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
     amount INT NOT NULL CHECK (amount > 0),
+    reason VARCHAR,
+    batch_id UUID,
+    message VARCHAR(255),
+    category VARCHAR CHECK (category IN ('kudos', 'payback', 'gift')),
+    reversal_of BIGINT UNIQUE REFERENCES transactions(id),
+    reversed_at TIMESTAMP,
+    kind VARCHAR CHECK (kind IN ('allowance', 'expiry')), -- NULL for transfers, grants and refunds
     created_at TIMESTAMP DEFAULT now()
 );
 
@@ -21,17 +29,128 @@ CREATE TABLE IF NOT EXISTS merch (
     name VARCHAR UNIQUE NOT NULL,
     price INT NOT NULL CHECK (price > 0),
     is_selling BOOLEAN DEFAULT true,
+    stock INT CHECK (stock >= 0), -- NULL means unlimited
+    max_per_user INT CHECK (max_per_user > 0), -- NULL means no per-user limit
+    description TEXT NOT NULL DEFAULT '',
+    category VARCHAR NOT NULL DEFAULT '',
+    image_url VARCHAR NOT NULL DEFAULT '',
     created_at TIMESTAMP DEFAULT now()
 );
 
+-- Sizes and colors of merch, stock of merch with variants is tracked per variant
+CREATE TABLE IF NOT EXISTS merch_variants (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merch_id UUID REFERENCES merch(id) ON DELETE CASCADE NOT NULL,
+    size VARCHAR NOT NULL DEFAULT '',
+    color VARCHAR NOT NULL DEFAULT '',
+    stock INT CHECK (stock >= 0), -- NULL means unlimited
+    created_at TIMESTAMP DEFAULT now(),
+    UNIQUE (merch_id, size, color)
+);
+
+-- Scheduled price changes, each row sets either a fixed sale price or a percent discount
+CREATE TABLE IF NOT EXISTS price_schedules (
+    id BIGSERIAL PRIMARY KEY,
+    merch_id UUID REFERENCES merch(id) ON DELETE CASCADE NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    sale_price INT CHECK (sale_price > 0),
+    percent_off INT CHECK (percent_off BETWEEN 1 AND 99),
+    created_at TIMESTAMP DEFAULT now(),
+    CHECK (ends_at > starts_at),
+    CHECK ((sale_price IS NULL) <> (percent_off IS NULL))
+);
+
 CREATE TABLE purchases (
     id BIGSERIAL PRIMARY KEY,
-    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL, -- owner of the item
+    buyer_id UUID REFERENCES users(id) ON DELETE SET NULL, -- differs from user_id for gifts
     merch_id UUID REFERENCES merch(id) NOT NULL,
+    variant_id UUID REFERENCES merch_variants(id),
     price INT NOT NULL CHECK (price > 0),
+    status VARCHAR NOT NULL DEFAULT 'placed' CHECK (status IN ('placed', 'ready_for_pickup', 'delivered', 'cancelled')),
+    status_updated_at TIMESTAMP DEFAULT now(),
+    reversed_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT now()
 );
 
+CREATE TABLE IF NOT EXISTS cart_items (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    merch_id UUID REFERENCES merch(id) ON DELETE CASCADE NOT NULL,
+    variant_id UUID REFERENCES merch_variants(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP DEFAULT now(),
+    UNIQUE NULLS NOT DISTINCT (user_id, merch_id, variant_id)
+);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    merch_id UUID REFERENCES merch(id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (user_id, merch_id)
+);
+
+-- Promo codes, max_uses and max_uses_per_user are unlimited when NULL
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR UNIQUE NOT NULL,
+    discount_type VARCHAR NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    discount_value INT NOT NULL CHECK (discount_value > 0),
+    max_uses INT CHECK (max_uses > 0),
+    max_uses_per_user INT CHECK (max_uses_per_user > 0),
+    uses INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMP DEFAULT now(),
+    CHECK (discount_type <> 'percent' OR discount_value < 100),
+    CHECK (max_uses IS NULL OR uses <= max_uses)
+);
+
+-- Merch the promo code is restricted to, no rows means any merch
+CREATE TABLE IF NOT EXISTS promo_code_merch (
+    promo_code_id BIGINT REFERENCES promo_codes(id) ON DELETE CASCADE NOT NULL,
+    merch_id UUID REFERENCES merch(id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (promo_code_id, merch_id)
+);
+
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id BIGSERIAL PRIMARY KEY,
+    promo_code_id BIGINT REFERENCES promo_codes(id) NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    purchase_id BIGINT UNIQUE REFERENCES purchases(id) NOT NULL,
+    discount INT NOT NULL CHECK (discount >= 0),
+    created_at TIMESTAMP DEFAULT now()
+);
+
+-- Coins with an expiry date, e.g. periodic allowances. Spending consumes lots that expire first,
+-- the rest of users.balance never expires. Unspent coins are removed from the balance on expiry.
+CREATE TABLE IF NOT EXISTS coin_lots (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    amount INT NOT NULL CHECK (amount > 0),
+    remaining INT NOT NULL CHECK (remaining >= 0 AND remaining <= amount),
+    allowance_period DATE, -- start of the allowance period the lot was issued for
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP DEFAULT now(),
+    UNIQUE (user_id, allowance_period)
+);
+
+-- Ownership changes of purchased items, purchases.user_id holds the current owner
+CREATE TABLE IF NOT EXISTS item_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    purchase_id BIGINT REFERENCES purchases(id) ON DELETE CASCADE NOT NULL,
+    from_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    to_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+-- Refunds of purchases are logged as transactions from the system account
+ALTER TABLE transactions ADD COLUMN purchase_id BIGINT UNIQUE REFERENCES purchases(id);
+
+-- Counterparty of administrative grants and clawbacks, cannot log in (empty password hash)
+INSERT INTO users (id, login, password, balance) VALUES
+    ('00000000-0000-0000-0000-000000000000', 'system', '', 0);
+
 INSERT INTO merch (name, price) VALUES
     ('t-shirt', 80),
     ('cup', 20),
@@ -46,5 +165,14 @@ INSERT INTO merch (name, price) VALUES
 
 CREATE INDEX idx_transactions_from_user ON transactions(from_user_id);
 CREATE INDEX idx_transactions_to_user ON transactions(to_user_id);
+CREATE INDEX idx_transactions_created ON transactions(created_at);
+CREATE INDEX idx_transactions_batch ON transactions(batch_id) WHERE batch_id IS NOT NULL;
 CREATE INDEX idx_purchases_user ON purchases(user_id);
-CREATE INDEX idx_merch_name ON merch(name);
\ No newline at end of file
+CREATE INDEX idx_purchases_buyer ON purchases(buyer_id);
+CREATE INDEX idx_coin_lots_user ON coin_lots(user_id, expires_at) WHERE remaining > 0;
+CREATE INDEX idx_coin_lots_expires ON coin_lots(expires_at) WHERE remaining > 0;
+CREATE INDEX idx_item_transfers_purchase ON item_transfers(purchase_id);
+CREATE INDEX idx_merch_name ON merch(name);
+CREATE INDEX idx_merch_category ON merch(category);
+CREATE INDEX idx_promo_redemptions_code_user ON promo_redemptions(promo_code_id, user_id);
+CREATE INDEX idx_price_schedules_merch ON price_schedules(merch_id, starts_at, ends_at);
\ No newline at end of file